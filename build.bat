@@ -1,3 +1,4 @@
 copy config.txt bin\config.txt
 @REM Build without a cmd window opening.
-go build -o bin\MyLocalhostGo.exe -ldflags -H=windowsgui .
\ No newline at end of file
+@REM The tag "sqlite_fts5" enables the full-text search of SQLite, used by the Youtube database.
+go build -tags sqlite_fts5 -o bin\MyLocalhostGo.exe -ldflags -H=windowsgui .
\ No newline at end of file
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mylocalhost/config"
+	"mylocalhost/logger"
+	netflix "mylocalhost/sites/Netflix/playlist"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The commands which can be given as first argument to the program, to run them instead of the server.
+// They receive the stores of the sites and the next arguments.
+var commands = map[string]func(stores *siteStores, args []string) error{
+	"youtube-purge-deleted":  purgeDeletedYoutubeVideosCommand,
+	"youtube-import-takeout": importYoutubeTakeoutCommand,
+	"netflix-revert":         revertNetflixVideoCommand,
+	"netflix-export":         exportNetflixPlaylistCommand,
+	"netflix-statuses":       netflixStatusesCommand,
+}
+
+// Run the given command and log its result.
+func runCommand(stores *siteStores, name string, args []string) error {
+	var command, keyExists = commands[name]
+	if keyExists == false {
+		return fmt.Errorf("Unknown command \"%s\"", name)
+	}
+
+	var commandErr = command(stores, args)
+	if commandErr != nil {
+		logger.WriteError("[%s] %v", name, commandErr)
+	}
+	return commandErr
+}
+
+// Write the result of a command in the log and in the standard output.
+func writeCommandResult(name string, text string, v ...interface{}) {
+	var result = fmt.Sprintf(text, v...)
+	logger.WriteLog("[%s] %s", name, result)
+	fmt.Println(result)
+}
+
+// Delete for good the Youtube videos deleted for longer than the config "Youtube.ratedVideos.purgeDeletedAfterDays".
+func purgeDeletedYoutubeVideosCommand(stores *siteStores, args []string) error {
+	var days = config.GetInt("Youtube.ratedVideos.purgeDeletedAfterDays", 30)
+	if days < 0 {
+		return fmt.Errorf("The config Youtube.ratedVideos.purgeDeletedAfterDays can't be negative")
+	}
+
+	var purgedCount, purgeErr = stores.youtube.PurgeDeletedVideos(time.Duration(days) * 24 * time.Hour)
+	if purgeErr != nil {
+		return purgeErr
+	}
+	writeCommandResult("youtube-purge-deleted", "%d videos deleted for more than %d days have been purged", purgedCount, days)
+	return nil
+}
+
+// Import the likes of a Google Takeout export.
+//
+// Arguments: the path of "Liked videos.csv", and optionally the path of "watch-history.json".
+func importYoutubeTakeoutCommand(stores *siteStores, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("Usage: youtube-import-takeout <Liked videos.csv> [watch-history.json]")
+	}
+	var watchHistoryFilePath = ""
+	if len(args) == 2 {
+		watchHistoryFilePath = args[1]
+	}
+
+	var report, importErr = stores.youtube.ImportTakeout(args[0], watchHistoryFilePath)
+	if importErr != nil {
+		return importErr
+	}
+	var reportData, marshalErr = json.MarshalIndent(report, "", "\t")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	writeCommandResult("youtube-import-takeout", "%s", reportData)
+	return nil
+}
+
+// Revert a Netflix video to an earlier state.
+//
+// Arguments: the video id, the id of an entry of its history or a date (like 2024-05-17), and optionally --dry-run to preview the changes.
+func revertNetflixVideoCommand(stores *siteStores, args []string) error {
+	var usageErr = fmt.Errorf("Usage: netflix-revert <videoId> <updateId|date> [--dry-run]")
+	if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "--dry-run") {
+		return usageErr
+	}
+	var videoId, convErr = strconv.ParseInt(args[0], 10, 64)
+	if convErr != nil {
+		return usageErr
+	}
+	//.. A date always has a "-", so a year alone isn't taken for an id.
+	var target = netflix.RevertTarget{}
+	if strings.Contains(args[1], "-") {
+		target.At = args[1]
+	} else if target.UpdateId, convErr = strconv.ParseInt(args[1], 10, 64); convErr != nil {
+		return usageErr
+	}
+
+	var result, revertErr = stores.netflix.RevertVideo(videoId, target, len(args) == 3)
+	if revertErr == sql.ErrNoRows {
+		return fmt.Errorf("The video %d or the entry of its history wasn't found", videoId)
+	} else if revertErr != nil {
+		return revertErr
+	}
+	var resultData, marshalErr = json.MarshalIndent(result, "", "\t")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	writeCommandResult("netflix-revert", "%s", resultData)
+	return nil
+}
+
+// Export the Netflix playlist in a file.
+//
+// Arguments: the path of the file, and the parameters of /netflix/export like format=ndjson or genre=Drama.
+func exportNetflixPlaylistCommand(stores *siteStores, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: netflix-export <file> [format=csv|ndjson|letterboxd] [columns=title,genres] [genre=Drama]...")
+	}
+	var values = url.Values{}
+	for _, arg := range args[1:] {
+		var name, value, hasValue = strings.Cut(arg, "=")
+		if hasValue == false {
+			return fmt.Errorf("The parameter \"%s\" is invalid (should be like name=value)", arg)
+		}
+		values.Add(name, value)
+	}
+	var options, optionsErr = netflix.ParseExportOptions(values)
+	if optionsErr != nil {
+		return optionsErr
+	}
+
+	var file, createErr = os.Create(args[0])
+	if createErr != nil {
+		return createErr
+	}
+	var count, exportErr = stores.netflix.ExportPlaylist(file, options)
+	if closeErr := file.Close(); exportErr == nil {
+		exportErr = closeErr
+	}
+	if exportErr != nil {
+		return exportErr
+	}
+	writeCommandResult("netflix-export", "%d videos exported to %s", count, args[0])
+	return nil
+}
+
+// List the statuses received from my Chrome extension with their action, or give the action of a status.
+//
+// Arguments: none to list them, or a status and its action (add, remove or unknown).
+func netflixStatusesCommand(stores *siteStores, args []string) error {
+	if len(args) == 0 {
+		var statuses, statusesErr = stores.netflix.GetStatuses()
+		if statusesErr != nil {
+			return statusesErr
+		}
+		var statusesData, marshalErr = json.MarshalIndent(statuses, "", "\t")
+		if marshalErr != nil {
+			return marshalErr
+		}
+		writeCommandResult("netflix-statuses", "%s", statusesData)
+		return nil
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: netflix-statuses [<status> <add|remove|unknown>]")
+	}
+
+	var changedCount, setErr = stores.netflix.SetStatusAction(args[0], args[1])
+	if setErr == sql.ErrNoRows {
+		return fmt.Errorf("The status \"%s\" was never received", args[0])
+	} else if setErr != nil {
+		return setErr
+	}
+	writeCommandResult("netflix-statuses", "The action of the status \"%s\" is now %s, %d statuses received have been changed", args[0], args[1], changedCount)
+	return nil
+}
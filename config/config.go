@@ -2,12 +2,19 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 )
 
 var configs = map[string]string{
-	"server.port":                            "8801",
-	"Youtube.ratedVideos.cacheVideoRankings": "false",
+	"server.port":                               "8801",
+	"database.journalMode":                      "WAL",
+	"database.busyTimeoutMs":                    "5000",
+	"database.maxOpenConnections":               "0",
+	"database.maxIdleConnections":               "2",
+	"Youtube.ratedVideos.cacheVideoRankings":    "off",
+	"Youtube.ratedVideos.cacheSize":             "1000",
+	"Youtube.ratedVideos.purgeDeletedAfterDays": "30",
 }
 
 func Read() error {
@@ -59,3 +66,13 @@ func GetBoolean(key string, defaultValue bool) bool {
 		return b
 	}
 }
+
+// Return `defaultValue` if the config value is not a integer.
+func GetInt(key string, defaultValue int) int {
+	//.. If the key is not present, the value is an empty string.
+	var value, convErr = strconv.Atoi(configs[key])
+	if convErr != nil {
+		return defaultValue
+	}
+	return value
+}
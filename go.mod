@@ -2,4 +2,4 @@ module mylocalhost
 
 go 1.19
 
-require github.com/mattn/go-sqlite3 v1.14.28 // indirect
+require github.com/mattn/go-sqlite3 v1.14.28
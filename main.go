@@ -1,13 +1,23 @@
+// The local server of my Chrome extensions, which saves what I do on Netflix and Youtube in SQLite databases.
+//
+// It must be built with the tag "sqlite_fts5", for the full-text search of the Youtube videos:
+//
+//	go build -tags sqlite_fts5
 package main
 
 import (
+	"context"
+	"fmt"
 	"mylocalhost/config"
 	"mylocalhost/logger"
 	netflix "mylocalhost/sites/Netflix/playlist"
 	youtube_ratedVideos "mylocalhost/sites/Youtube/ratedvideos"
+	database "mylocalhost/utils/database"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 )
 
 func main() {
@@ -21,15 +31,77 @@ func main() {
 		os.Exit(1)
 	}
 
+	//.. The databases are opened at startup to apply the migrations of their schema before receiving any request.
+	var stores, openErr = openStores()
+	if openErr != nil {
+		logger.WriteError("%v", openErr)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 {
+		//.. A command is given, it's run instead of the server.
+		var commandErr = runCommand(stores, os.Args[1], os.Args[2:])
+		stores.close()
+		if commandErr != nil {
+			fmt.Println(commandErr)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var server = http.NewServeMux()
-	server.HandleFunc("/netflix/save-video-to-playlist", netflix.SaveVideoToPlaylistRequestHandler)
-	server.HandleFunc("/youtube/rating/get-rated-videos", youtube_ratedVideos.GetRatedVideosRequestHandler)
-	server.HandleFunc("/youtube/rating/set-video-rating", youtube_ratedVideos.SetVideoRatingRequestHandler)
+	server.HandleFunc("/netflix/save-video-to-playlist", stores.netflix.SaveVideoToPlaylistRequestHandler)
+	server.HandleFunc("/netflix/playlist", stores.netflix.PlaylistRequestHandler)
+	server.HandleFunc("/netflix/playlist/", stores.netflix.PlaylistRequestHandler)
+	server.HandleFunc("/netflix/playlist-diff", stores.netflix.PlaylistDiffRequestHandler)
+	server.HandleFunc("/netflix/export", stores.netflix.ExportRequestHandler)
+	server.HandleFunc("/netflix/recent-changes", stores.netflix.RecentChangesRequestHandler)
+	server.HandleFunc("/netflix/upcoming", stores.netflix.UpcomingRequestHandler)
+	server.HandleFunc("/netflix/calendar.ics", stores.netflix.CalendarRequestHandler)
+	server.HandleFunc("/netflix/people", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/people/", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/genres", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/genres/", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/moods", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/moods/", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/tags", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/netflix/tags/", stores.netflix.BrowseRequestHandler)
+	server.HandleFunc("/youtube/rating/get-rated-videos", stores.youtube.GetRatedVideosRequestHandler)
+	server.HandleFunc("/youtube/rating/set-video-rating", stores.youtube.SetVideoRatingRequestHandler)
+	server.HandleFunc("/youtube/rating/set-video-ratings", stores.youtube.SetVideoRatingsRequestHandler)
+	server.HandleFunc("/youtube/rating/search", stores.youtube.SearchRequestHandler)
+	server.HandleFunc("/youtube/rating/get-rating-history", stores.youtube.GetRatingHistoryRequestHandler)
+	server.HandleFunc("/youtube/rating/get-video-comment", stores.youtube.GetVideoCommentRequestHandler)
+	server.HandleFunc("/youtube/rating/set-video-comment", stores.youtube.SetVideoCommentRequestHandler)
+	server.HandleFunc("/youtube/rating/set-videos-downloaded", stores.youtube.SetVideosDownloadedRequestHandler)
+	server.HandleFunc("/youtube/rating/get-pending-downloads", stores.youtube.GetPendingDownloadsRequestHandler)
+	server.HandleFunc("/youtube/rating/delete-video", stores.youtube.DeleteVideoRequestHandler)
+	server.HandleFunc("/youtube/rating/restore-video", stores.youtube.RestoreVideoRequestHandler)
+	server.HandleFunc("/youtube/rating/import-takeout", stores.youtube.ImportTakeoutRequestHandler)
+	server.HandleFunc("/youtube/channels", stores.youtube.ChannelsRequestHandler)
+	server.HandleFunc("/youtube/channels/", stores.youtube.ChannelsRequestHandler)
+	server.HandleFunc("/youtube/diagnostics/cache", stores.youtube.CacheDiagnosticsRequestHandler)
 	var serverPort = config.Get("server.port")
-	var err = http.ListenAndServe(":"+serverPort, server)
+	var httpServer = &http.Server{Addr: ":" + serverPort, Handler: server}
 
-	netflix.CloseDatabaseConnection()
-	youtube_ratedVideos.CloseDatabaseConnection()
+	//.. When the program is stopped, the server waits for the requests being handled, then the stores are closed.
+	var stopSignals = make(chan os.Signal, 1)
+	signal.Notify(stopSignals, os.Interrupt, syscall.SIGTERM)
+	var shutdownDone = make(chan struct{})
+	go func() {
+		<-stopSignals
+		httpServer.Shutdown(context.Background())
+		close(shutdownDone)
+	}()
+
+	var err = httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		//.. ListenAndServe returns as soon as the shutdown starts.
+		<-shutdownDone
+		err = nil
+	}
+
+	stores.close()
 
 	if err != nil {
 		logger.WriteError("Error listening at port "+serverPort+"\n%v", err)
@@ -37,6 +109,41 @@ func main() {
 	}
 }
 
+// The stores of the sites, opened at startup and closed before exiting.
+type siteStores struct {
+	netflix *netflix.Store
+	youtube *youtube_ratedVideos.Store
+}
+
+// Open the store of each site, with the database file and the options of the config.
+func openStores() (*siteStores, error) {
+	var connectionOptions = database.ReadConnectionOptions()
+	var cacheOptions, cacheOptionsErr = youtube_ratedVideos.ReadCacheOptions()
+	if cacheOptionsErr != nil {
+		return nil, cacheOptionsErr
+	}
+
+	var netflixStore, netflixErr = netflix.OpenStore(config.Get("Netflix.databaseFilePath"), connectionOptions)
+	if netflixErr != nil {
+		return nil, fmt.Errorf("Error opening the Netflix database\n%w", netflixErr)
+	}
+	var youtubeStore, youtubeErr = youtube_ratedVideos.OpenStore(config.Get("Youtube.ratedVideos.databaseFilePath"), connectionOptions, cacheOptions)
+	if youtubeErr != nil {
+		netflixStore.Close()
+		return nil, fmt.Errorf("Error opening the Youtube database\n%w", youtubeErr)
+	}
+	return &siteStores{netflix: netflixStore, youtube: youtubeStore}, nil
+}
+
+func (stores *siteStores) close() {
+	if closeErr := stores.netflix.Close(); closeErr != nil {
+		logger.WriteError("Error closing the Netflix database\n%v", closeErr)
+	}
+	if closeErr := stores.youtube.Close(); closeErr != nil {
+		logger.WriteError("Error closing the Youtube database\n%v", closeErr)
+	}
+}
+
 // Set the current working directory to the one where the current executable is.
 func setChdir() error {
 	var executableFilePath, executableErr = os.Executable()
@@ -0,0 +1,241 @@
+package netflix
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	dates "mylocalhost/utils/dates"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A video of my playlist with a date of availability.
+type AvailableTitle struct {
+	VideoId int64  `json:"videoId"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	// When the video is available on Netflix.
+	AvailableAt string `json:"availableAt"`
+	// The previous date of availability, when Netflix has moved it.
+	PreviousAvailableAt string `json:"previousAvailableAt,omitempty"`
+	// The number of times Netflix has moved the date of availability, and the last time.
+	RescheduleCount int    `json:"rescheduleCount"`
+	RescheduledAt   string `json:"rescheduledAt,omitempty"`
+
+	availableAt time.Time
+	// When the date of availability was saved or changed for the last time.
+	lastModified string
+}
+
+// The videos available in a week.
+type UpcomingWeek struct {
+	// The monday of the week, like 2024-05-13.
+	Week   string           `json:"week"`
+	Titles []AvailableTitle `json:"titles"`
+}
+
+// The layouts of the dates of availability which aren't a timestamp.
+var availabilityLayouts = []string{time.RFC3339, "2006-01-02 15:04:05.000", "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// Parse the value of the column availability_starttime: a timestamp in milliseconds or seconds, or a date.
+func parseAvailabilityStartTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if timestamp, convErr := strconv.ParseInt(value, 10, 64); convErr == nil {
+		//.. Netflix sends the timestamps in milliseconds, but I accept the seconds too.
+		if timestamp > 100000000000 {
+			return time.UnixMilli(timestamp), true
+		}
+		return time.Unix(timestamp, 0), true
+	}
+	for _, layout := range availabilityLayouts {
+		if parsedTime, parseErr := time.ParseInLocation(layout, value, time.Local); parseErr == nil {
+			return parsedTime, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Get the videos in my playlist with a valid date of availability, from the soonest to the latest.
+func (store *Store) getAvailableTitles() ([]AvailableTitle, error) {
+	var rows, queryErr = store.connection.Query("SELECT video_id, type, title, availability_starttime, created_at FROM playlist WHERE availability_starttime != '' AND " +
+		inListCondition + " ORDER BY rowid;")
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var titles = []AvailableTitle{}
+	for rows.Next() {
+		var title = AvailableTitle{}
+		var availabilityStartTime string
+		if scanErr := rows.Scan(&title.VideoId, &title.Type, &title.Title, &availabilityStartTime, &title.lastModified); scanErr != nil {
+			return nil, scanErr
+		}
+		var availableAt, isValid = parseAvailabilityStartTime(availabilityStartTime)
+		if isValid == false {
+			continue
+		}
+		title.availableAt = availableAt
+		title.AvailableAt = dates.ToString(availableAt)
+		titles = append(titles, title)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	if changesErr := store.addAvailabilityChanges(titles); changesErr != nil {
+		return nil, changesErr
+	}
+	sort.SliceStable(titles, func(i, j int) bool {
+		return titles[i].availableAt.Before(titles[j].availableAt)
+	})
+	return titles, nil
+}
+
+// Add to the videos the changes of their date of availability, read from the table playlist_updates.
+func (store *Store) addAvailabilityChanges(titles []AvailableTitle) error {
+	var titlesByVideoId = make(map[int64]*AvailableTitle)
+	for i := range titles {
+		titlesByVideoId[titles[i].VideoId] = &titles[i]
+	}
+
+	var rows, queryErr = store.connection.Query(`SELECT playlist_updates.video_id, json_extract(change.value, '$.oldValue'), playlist_updates.updated_at
+		FROM playlist_updates
+		INNER JOIN json_each(playlist_updates.updates) AS change
+		WHERE json_extract(change.value, '$.column') = 'availability_starttime'
+		ORDER BY playlist_updates.updated_at, playlist_updates.rowid;`)
+	if queryErr != nil {
+		return queryErr
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var videoId int64
+		var oldValue sql.NullString
+		var updatedAt string
+		if scanErr := rows.Scan(&videoId, &oldValue, &updatedAt); scanErr != nil {
+			return scanErr
+		}
+		var title, keyExists = titlesByVideoId[videoId]
+		if keyExists == false {
+			continue
+		}
+		title.lastModified = updatedAt
+		//.. A first date received for a video saved without one isn't a new schedule.
+		if previousAvailableAt, isValid := parseAvailabilityStartTime(oldValue.String); isValid {
+			title.PreviousAvailableAt = dates.ToString(previousAvailableAt)
+			title.RescheduleCount++
+			title.RescheduledAt = updatedAt
+		}
+	}
+	return rows.Err()
+}
+
+// Get the videos in my playlist available after the given time, grouped by week.
+func (store *Store) GetUpcomingTitles(now time.Time) ([]UpcomingWeek, error) {
+	var titles, titlesErr = store.getAvailableTitles()
+	if titlesErr != nil {
+		return nil, titlesErr
+	}
+
+	var weeks = []UpcomingWeek{}
+	for _, title := range titles {
+		if title.availableAt.After(now) == false {
+			continue
+		}
+		var week = weekStart(title.availableAt).Format("2006-01-02")
+		if len(weeks) == 0 || weeks[len(weeks)-1].Week != week {
+			weeks = append(weeks, UpcomingWeek{Week: week})
+		}
+		weeks[len(weeks)-1].Titles = append(weeks[len(weeks)-1].Titles, title)
+	}
+	return weeks, nil
+}
+
+// Get the monday of the week of the given time, at midnight.
+func weekStart(t time.Time) time.Time {
+	var year, month, day = t.Date()
+	var daysSinceMonday = (int(t.Weekday()) + 6) % 7
+	return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// Write the iCalendar feed of the videos in my playlist with a date of availability.
+//
+// Each video is a single event, whose UID never changes: when Netflix moves the date, the SEQUENCE is increased
+// so the calendar apps update the event instead of adding another one.
+func (store *Store) WriteCalendar(w io.Writer) error {
+	var titles, titlesErr = store.getAvailableTitles()
+	if titlesErr != nil {
+		return titlesErr
+	}
+
+	var lines = []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//mylocalhost//Netflix playlist//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:Netflix playlist",
+	}
+	for _, title := range titles {
+		var description = "Available on Netflix: " + title.AvailableAt
+		if title.PreviousAvailableAt != "" {
+			description += "\nPreviously: " + title.PreviousAvailableAt
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:netflix-%d@mylocalhost", title.VideoId),
+			"DTSTAMP:"+icalDateTime(title.lastModified),
+			"LAST-MODIFIED:"+icalDateTime(title.lastModified),
+			"SEQUENCE:"+strconv.Itoa(title.RescheduleCount),
+			"DTSTART:"+title.availableAt.UTC().Format("20060102T150405Z"),
+			"SUMMARY:"+icalText(title.Title),
+			"DESCRIPTION:"+icalText(description),
+			fmt.Sprintf("URL:https://www.netflix.com/title/%d", title.VideoId),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, writeErr := io.WriteString(w, foldICalLine(line)+"\r\n"); writeErr != nil {
+			return writeErr
+		}
+	}
+	return nil
+}
+
+// Format a date saved in database as a date of iCalendar, in UTC.
+func icalDateTime(date string) string {
+	var parsedTime, parseErr = time.ParseInLocation("2006-01-02 15:04:05.000", date, time.Local)
+	if parseErr != nil {
+		parsedTime = time.Now()
+	}
+	return parsedTime.UTC().Format("20060102T150405Z")
+}
+
+// Escape a text of iCalendar.
+func icalText(text string) string {
+	var replacer = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n")
+	return replacer.Replace(text)
+}
+
+// Fold a line of iCalendar longer than 75 bytes, without splitting a UTF-8 character.
+func foldICalLine(line string) string {
+	var builder strings.Builder
+	var lineLength = 0
+	for _, character := range line {
+		var characterLength = len(string(character))
+		if lineLength+characterLength > 75 {
+			builder.WriteString("\r\n ")
+			lineLength = 1
+		}
+		builder.WriteRune(character)
+		lineLength += characterLength
+	}
+	return builder.String()
+}
@@ -4,44 +4,53 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"mylocalhost/config"
 	utils "mylocalhost/utils/database"
 	dates "mylocalhost/utils/dates"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// The data of a video sent by my Chrome extension.
+//
+// The fields with a tag "db" are saved in this column of the playlist.
+// When the video is saved again, they are compared following their tag "merge" (see diff.go).
 type videoData struct {
 	Rowid   int64
-	VideoId int64
-	Type    string
-	Title   string
+	VideoId int64  `validate:"required" db:"video_id" merge:"-"`
+	Type    string `db:"type"`
+	Title   string `validate:"required,nonempty" db:"title"`
 
-	Status string
+	//.. Each status received is saved as an event (see statuses.go).
+	Status string `validate:"required,nonempty" db:"status" merge:"-"`
 
-	Casting   string
-	Creators  string
-	Directors string
-	Writers   string
+	Casting   string `db:"casting"`
+	Creators  string `db:"creators"`
+	Directors string `db:"directors"`
+	Writers   string `db:"writers"`
 
-	Genres string
-	Mood   string
-	Tags   string
+	Genres string `db:"genres"`
+	Mood   string `db:"mood"`
+	//.. If there are tags saved in database, but the tags from the webpage are empty,
+	//.. it might be because the data were retrieved in the variable "netflix.falcorCache",
+	//.. wich doesn't contain the tags.
+	Tags string `db:"tags" merge:"ignoreEmpty"`
 
-	AgeAdvised       int
-	AgeAdvisedReason string
+	AgeAdvised       int    `db:"age_advised"`
+	AgeAdvisedReason string `db:"age_advised_reason"`
 
-	Synopsis string
+	Synopsis string `db:"synopsis"`
 
-	SeasonCount    int
-	NumSeasonLabel string
-	EpisodeCount   int
+	SeasonCount    int    `db:"season_count"`
+	NumSeasonLabel string `db:"num_season_label"`
+	EpisodeCount   int    `db:"episode_count"`
 
-	DurationSec int64
+	DurationSec int64 `db:"duration_sec"`
 
-	AvailabilityStartTime string
+	AvailabilityStartTime string `db:"availability_starttime"`
 
-	DataFrom_ string `json:"_dataFrom"`
+	//.. Only updated with the other columns.
+	DataFrom_ string `json:"_dataFrom" db:"_data_from" merge:"withOthers"`
 
 	CreatedAt string
 	UpdatedAt string
@@ -56,81 +65,61 @@ type saveVideoToPlaylistResult struct {
 	UpdatedColumns []string `json:"updatedColumns"`
 	OldValues      []any    `json:"oldValues"`
 	NewValues      []any    `json:"newValues"`
-}
-
-var _connection *sql.DB
 
-func openConnection() error {
-	if _connection != nil {
-		return nil
-	}
+	// If the changes were rolled back instead of committed, to preview them.
+	DryRun bool `json:"dryRun,omitempty"`
+}
 
-	var dbFilePath = config.Get("Netflix.databaseFilePath")
+// The playlist of a database.
+type Store struct {
+	connection *sql.DB
+}
 
-	var connection, dbFileExists, connectionErr = utils.OpenSQLiteConnection(dbFilePath)
+// Open the database and bring its schema up to date. The store must be closed once it's no longer used.
+func OpenStore(dbFilePath string, connectionOptions utils.ConnectionOptions) (*Store, error) {
+	var connection, _, connectionErr = utils.OpenSQLiteConnection(dbFilePath, connectionOptions)
 	if connectionErr != nil {
-		return connectionErr
-	}
-	_connection = connection
-
-	if dbFileExists == false {
-		var _, execErr = connection.Exec(`
-		CREATE TABLE "playlist" (
-			"video_id"	INTEGER NOT NULL CHECK("video_id" > 0) UNIQUE,
-			"type"	TEXT NOT NULL DEFAULT '',
-			"title"	TEXT NOT NULL CHECK("title" != ''),
-			"status"	TEXT NOT NULL CHECK("status" != ''),
-			"casting"	TEXT NOT NULL DEFAULT '',
-			"creators"	TEXT NOT NULL DEFAULT '',
-			"directors"	TEXT NOT NULL DEFAULT '',
-			"writers"	TEXT NOT NULL DEFAULT '',
-			"genres"	TEXT NOT NULL DEFAULT '',
-			"mood"	TEXT NOT NULL DEFAULT '',
-			"tags"	TEXT NOT NULL DEFAULT '',
-			"age_advised"	INTEGER NOT NULL DEFAULT 0,
-			"age_advised_reason"	TEXT NOT NULL DEFAULT '',
-			"synopsis"	TEXT NOT NULL DEFAULT '',
-			"season_count"	INTEGER NOT NULL DEFAULT 0,
-			"num_season_label"	TEXT NOT NULL DEFAULT '',
-			"episode_count"	INTEGER NOT NULL DEFAULT 0,
-			"duration_sec"	INTEGER NOT NULL DEFAULT 0,
-			"availability_starttime"	TEXT NOT NULL DEFAULT '',
-			"_data_from"	TEXT NOT NULL DEFAULT '',
-			"created_at"	TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime')),
-			"updated_at"	TEXT NOT NULL DEFAULT ''
-		);
-		
-		CREATE TABLE "playlist_updates" (
-			"video_id"	INTEGER NOT NULL,
-			"updated_at"	TEXT NOT NULL,
-			"updates"	TEXT NOT NULL
-		);
-		
-		CREATE INDEX "idx_playlist_video_id" ON "playlist" ("video_id");`)
-		if execErr != nil {
-			return execErr
-		}
+		return nil, connectionErr
 	}
 
-	return nil
+	if migrateErr := utils.Migrate(connection, "Netflix", migrations); migrateErr != nil {
+		connection.Close()
+		return nil, migrateErr
+	}
+	return &Store{connection: connection}, nil
 }
 
 // Insert or update the given video.
-func saveVideoToPlaylist(videoToAdd *videoData) saveVideoToPlaylistResult {
-	var result = saveVideoToPlaylistResult{}
-	if openErr := openConnection(); openErr != nil {
-		result.Error = openErr.Error()
-		return result
-	}
+//
+// With dryRun, everything is done like a real save but the transaction is rolled back instead of committed.
+func (store *Store) saveVideoToPlaylist(videoToAdd *videoData, dryRun bool) saveVideoToPlaylistResult {
+	var result = saveVideoToPlaylistResult{DryRun: dryRun}
 
-	var savedVideo, getVideoErr = getVideoFromVideoId(videoToAdd.VideoId)
+	var savedVideo, getVideoErr = store.getVideoFromVideoId(videoToAdd.VideoId)
 	if getVideoErr != nil {
 		if getVideoErr == sql.ErrNoRows {
 			result.Query = "INSERT"
-			var insertErr = insertVideo(videoToAdd)
+			var transaction, transactionErr = store.connection.Begin()
+			if transactionErr != nil {
+				result.Error = "TransactionErr: " + transactionErr.Error()
+				return result
+			}
+			defer transaction.Rollback()
+
+			var insertErr = insertVideo(transaction, videoToAdd)
+			if insertErr == nil {
+				insertErr = insertStatusEvent(transaction, videoToAdd.VideoId, videoToAdd.Status, dates.NowToString(), true)
+			}
 			if insertErr == nil {
+				insertErr = syncVideoLinks(transaction, videoToAdd.VideoId, videoLinkValues(videoToAdd))
+			}
+			if insertErr == nil && dryRun == false {
+				insertErr = transaction.Commit()
+			}
+			if insertErr == nil && dryRun == false {
+				//.. The rowid of a rolled back insert doesn't exist, so it stays 0.
 				result.Rowid = videoToAdd.Rowid
-			} else {
+			} else if insertErr != nil {
 				result.Error = insertErr.Error()
 			}
 		} else {
@@ -140,107 +129,10 @@ func saveVideoToPlaylist(videoToAdd *videoData) saveVideoToPlaylistResult {
 		result.Rowid = savedVideo.Rowid
 		videoToAdd.Rowid = savedVideo.Rowid
 
-		var columnsToUpdate []string
-		var oldValues []any
-		var newValues []any
-
-		if savedVideo.AgeAdvised != videoToAdd.AgeAdvised {
-			columnsToUpdate = append(columnsToUpdate, "age_advised")
-			oldValues = append(oldValues, savedVideo.AgeAdvised)
-			newValues = append(newValues, videoToAdd.AgeAdvised)
-		}
-		if savedVideo.AgeAdvisedReason != videoToAdd.AgeAdvisedReason {
-			columnsToUpdate = append(columnsToUpdate, "age_advised_reason")
-			oldValues = append(oldValues, savedVideo.AgeAdvisedReason)
-			newValues = append(newValues, videoToAdd.AgeAdvisedReason)
-		}
-		if savedVideo.AvailabilityStartTime != videoToAdd.AvailabilityStartTime {
-			columnsToUpdate = append(columnsToUpdate, "availability_starttime")
-			oldValues = append(oldValues, savedVideo.AvailabilityStartTime)
-			newValues = append(newValues, videoToAdd.AvailabilityStartTime)
-		}
-		if savedVideo.Casting != videoToAdd.Casting {
-			columnsToUpdate = append(columnsToUpdate, "casting")
-			oldValues = append(oldValues, savedVideo.Casting)
-			newValues = append(newValues, videoToAdd.Casting)
-		}
-		if savedVideo.Creators != videoToAdd.Creators {
-			columnsToUpdate = append(columnsToUpdate, "creators")
-			oldValues = append(oldValues, savedVideo.Creators)
-			newValues = append(newValues, videoToAdd.Creators)
-		}
-		if savedVideo.Directors != videoToAdd.Directors {
-			columnsToUpdate = append(columnsToUpdate, "directors")
-			oldValues = append(oldValues, savedVideo.Directors)
-			newValues = append(newValues, videoToAdd.Directors)
-		}
-		if savedVideo.DurationSec != videoToAdd.DurationSec {
-			columnsToUpdate = append(columnsToUpdate, "duration_sec")
-			oldValues = append(oldValues, savedVideo.DurationSec)
-			newValues = append(newValues, videoToAdd.DurationSec)
-		}
-		if savedVideo.EpisodeCount != videoToAdd.EpisodeCount {
-			columnsToUpdate = append(columnsToUpdate, "episode_count")
-			oldValues = append(oldValues, savedVideo.EpisodeCount)
-			newValues = append(newValues, videoToAdd.EpisodeCount)
-		}
-		if savedVideo.Genres != videoToAdd.Genres {
-			columnsToUpdate = append(columnsToUpdate, "genres")
-			oldValues = append(oldValues, savedVideo.Genres)
-			newValues = append(newValues, videoToAdd.Genres)
-		}
-		if savedVideo.Mood != videoToAdd.Mood {
-			columnsToUpdate = append(columnsToUpdate, "mood")
-			oldValues = append(oldValues, savedVideo.Mood)
-			newValues = append(newValues, videoToAdd.Mood)
-		}
-		if savedVideo.NumSeasonLabel != videoToAdd.NumSeasonLabel {
-			columnsToUpdate = append(columnsToUpdate, "num_season_label")
-			oldValues = append(oldValues, savedVideo.NumSeasonLabel)
-			newValues = append(newValues, videoToAdd.NumSeasonLabel)
-		}
-		if savedVideo.SeasonCount != videoToAdd.SeasonCount {
-			columnsToUpdate = append(columnsToUpdate, "season_count")
-			oldValues = append(oldValues, savedVideo.SeasonCount)
-			newValues = append(newValues, videoToAdd.SeasonCount)
-		}
-		if savedVideo.Synopsis != videoToAdd.Synopsis {
-			columnsToUpdate = append(columnsToUpdate, "synopsis")
-			oldValues = append(oldValues, savedVideo.Synopsis)
-			newValues = append(newValues, videoToAdd.Synopsis)
-		}
-		if savedVideo.Tags != videoToAdd.Tags && videoToAdd.Tags != "" {
-			//.. If there are tags saved in database, but the tags from the webpage are empty,
-			//.. it might be because the data were retrieved in the variable "netflix.falcorCache",
-			//.. wich doesn't contain the tags.
-			columnsToUpdate = append(columnsToUpdate, "tags")
-			oldValues = append(oldValues, savedVideo.Tags)
-			newValues = append(newValues, videoToAdd.Tags)
-		}
-		if savedVideo.Title != videoToAdd.Title {
-			columnsToUpdate = append(columnsToUpdate, "title")
-			oldValues = append(oldValues, savedVideo.Title)
-			newValues = append(newValues, videoToAdd.Title)
-		}
-		if savedVideo.Type != videoToAdd.Type {
-			columnsToUpdate = append(columnsToUpdate, "type")
-			oldValues = append(oldValues, savedVideo.Type)
-			newValues = append(newValues, videoToAdd.Type)
-		}
-		if savedVideo.Writers != videoToAdd.Writers {
-			columnsToUpdate = append(columnsToUpdate, "writers")
-			oldValues = append(oldValues, savedVideo.Writers)
-			newValues = append(newValues, videoToAdd.Writers)
-		}
+		var columnsToUpdate, oldValues, newValues = diffVideo(savedVideo, videoToAdd)
 
 		var numberColumnsToUpdate = len(columnsToUpdate)
 		if numberColumnsToUpdate > 0 {
-			if savedVideo.DataFrom_ != videoToAdd.DataFrom_ {
-				columnsToUpdate = append(columnsToUpdate, "_data_from")
-				oldValues = append(oldValues, savedVideo.DataFrom_)
-				newValues = append(newValues, videoToAdd.DataFrom_)
-			}
-
 			videoToAdd.UpdatedAt = dates.NowToString()
 
 			result.Query = "UPDATE"
@@ -251,24 +143,41 @@ func saveVideoToPlaylist(videoToAdd *videoData) saveVideoToPlaylistResult {
 			result.Query = "NONE"
 		}
 
-		var transaction, transactionErr = _connection.Begin()
+		var transaction, transactionErr = store.connection.Begin()
 		if transactionErr != nil {
 			result.Error = "TransactionErr: " + transactionErr.Error()
 			return result
 		}
 
-		var newStatus = savedVideo.Status + "\r\n\r\n" + videoToAdd.Status
-		videoToAdd.Status = newStatus
+		defer transaction.Rollback()
+
+		//.. Each status received is an event of my playlist, the column "status" keeps the current one.
 		var finalColumnsToUpdate = append(columnsToUpdate, "status")
 		newValues = append(newValues, videoToAdd.Status)
 		if updateErr := update(transaction, videoToAdd, finalColumnsToUpdate, newValues); updateErr != nil {
 			result.Error = "UpdateErr: " + updateErr.Error()
 			return result
 		}
+		if insertEventErr := insertStatusEvent(transaction, videoToAdd.VideoId, videoToAdd.Status, dates.NowToString(), false); insertEventErr != nil {
+			result.Error = "InsertStatusEventErr: " + insertEventErr.Error()
+			return result
+		}
+
+		//.. The people, genres, moods and tags linked to the video follow the columns updated.
+		var linkValues = make(map[string]string)
+		for i, column := range columnsToUpdate {
+			if value, isString := newValues[i].(string); isString {
+				linkValues[column] = value
+			}
+		}
+		if syncErr := syncVideoLinks(transaction, videoToAdd.VideoId, linkValues); syncErr != nil {
+			result.Error = "SyncLinksErr: " + syncErr.Error()
+			return result
+		}
 
 		if numberColumnsToUpdate > 0 {
 			//.. The video data has changed. I keep a historic of the changes.
-			if insertUpdatesErr := insertPlaylistUpdates(transaction, videoToAdd, columnsToUpdate, newValues, oldValues); insertUpdatesErr != nil {
+			if insertUpdatesErr := insertPlaylistUpdates(transaction, videoToAdd, columnsToUpdate, newValues, oldValues, ""); insertUpdatesErr != nil {
 				result.Error = "InsertUpdatesErr: " + insertUpdatesErr.Error()
 				if rollbackErr := transaction.Rollback(); rollbackErr != nil {
 					result.Error += "\nRollbackErr: " + rollbackErr.Error()
@@ -277,6 +186,10 @@ func saveVideoToPlaylist(videoToAdd *videoData) saveVideoToPlaylistResult {
 			}
 		}
 
+		if dryRun {
+			//.. The deferred rollback cancels the changes.
+			return result
+		}
 		if commitErr := transaction.Commit(); commitErr != nil {
 			result.Error = "CommitErr: " + commitErr.Error()
 		}
@@ -285,51 +198,30 @@ func saveVideoToPlaylist(videoToAdd *videoData) saveVideoToPlaylistResult {
 	return result
 }
 
-// Get the row id and the status for the given video id.
-func getVideoFromVideoId(videoId int64) (*videoData, error) {
-	var stmt, stmtErr = _connection.Prepare("SELECT rowid, type, title, status, casting, creators, directors, writers, genres, mood, tags, age_advised, age_advised_reason, synopsis, season_count, num_season_label, episode_count, duration_sec, availability_starttime, _data_from FROM playlist WHERE video_id = ?")
-	if stmtErr != nil {
-		return nil, stmtErr
-	}
-	defer stmt.Close()
+// A connection or a transaction.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Get the saved data of the given video id.
+func (store *Store) getVideoFromVideoId(videoId int64) (*videoData, error) {
+	return getVideo(store.connection, videoId)
+}
 
-	var rowid int64
-	var ttype string
-	var title string
-	var status string
-	var casting string
-	var creators string
-	var directors string
-	var writers string
-	var genres string
-	var mood string
-	var tags string
-	var ageAdvised int
-	var ageAdvisedReason string
-	var synopsis string
-	var seasonCount int
-	var numSeasonLabel string
-	var episodeCount int
-	var durationSec int64
-	var availabilityStartTime string
-	var dataFrom_ string
-	var scanErr = stmt.QueryRow(videoId).Scan(&rowid, &ttype, &title, &status, &casting, &creators, &directors, &writers, &genres, &mood, &tags, &ageAdvised, &ageAdvisedReason, &synopsis, &seasonCount, &numSeasonLabel, &episodeCount, &durationSec, &availabilityStartTime, &dataFrom_)
+func getVideo(querier queryRower, videoId int64) (*videoData, error) {
+	var video = &videoData{}
+	var scanErr = querier.QueryRow("SELECT rowid, "+strings.Join(videoColumns(), ", ")+" FROM playlist WHERE video_id = ?;", videoId).
+		Scan(append([]any{&video.Rowid}, videoFieldPointers(video)...)...)
 	if scanErr != nil {
 		return nil, scanErr
 	}
-	var video = &videoData{Rowid: rowid, VideoId: videoId, Type: ttype, Title: title, Status: status, Casting: casting, Creators: creators, Directors: directors, Writers: writers, Genres: genres, Mood: mood, Tags: tags, AgeAdvised: ageAdvised, AgeAdvisedReason: ageAdvisedReason, Synopsis: synopsis, SeasonCount: seasonCount, NumSeasonLabel: numSeasonLabel, EpisodeCount: episodeCount, DurationSec: durationSec, AvailabilityStartTime: availabilityStartTime, DataFrom_: dataFrom_}
 	return video, nil
 }
 
 // Insert a new video to the playlist.
-func insertVideo(video *videoData) error {
-	var stmt, stmtErr = _connection.Prepare("INSERT INTO playlist(video_id, type, title, status, casting, creators, directors, writers, genres, mood, tags, age_advised, age_advised_reason, synopsis, season_count, num_season_label, episode_count, duration_sec, availability_starttime, _data_from) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);")
-	if stmtErr != nil {
-		return stmtErr
-	}
-	defer stmt.Close()
-
-	var result, execErr = stmt.Exec(video.VideoId, video.Type, video.Title, video.Status, video.Casting, video.Creators, video.Directors, video.Writers, video.Genres, video.Mood, video.Tags, video.AgeAdvised, video.AgeAdvisedReason, video.Synopsis, video.SeasonCount, video.NumSeasonLabel, video.EpisodeCount, video.DurationSec, video.AvailabilityStartTime, video.DataFrom_)
+func insertVideo(transaction *sql.Tx, video *videoData) error {
+	var columns = videoColumns()
+	var result, execErr = transaction.Exec("INSERT INTO playlist("+strings.Join(columns, ", ")+") VALUES("+placeholders(len(columns))+");", videoFieldValues(video)...)
 	if execErr != nil {
 		return execErr
 	}
@@ -385,8 +277,9 @@ func update(transaction *sql.Tx, video *videoData, columnsToUpdate []string, new
 }
 
 // When video data have changed, I keep a historic of the changes.
-func insertPlaylistUpdates(transaction *sql.Tx, video *videoData, columnsToUpdate []string, newValues []any, oldValues []any) error {
-	var stmt, stmtErr = transaction.Prepare("INSERT INTO playlist_updates(video_id, updated_at, updates) VALUES(?, ?, ?);")
+// revertedTo is the date of the state restored when the changes are a revert, empty otherwise.
+func insertPlaylistUpdates(transaction *sql.Tx, video *videoData, columnsToUpdate []string, newValues []any, oldValues []any, revertedTo string) error {
+	var stmt, stmtErr = transaction.Prepare("INSERT INTO playlist_updates(video_id, updated_at, updates, reverted_to) VALUES(?, ?, ?, ?);")
 	if stmtErr != nil {
 		return stmtErr
 	}
@@ -406,7 +299,7 @@ func insertPlaylistUpdates(transaction *sql.Tx, video *videoData, columnsToUpdat
 	}
 	var updates = string(updatesData)
 
-	var result, execErr = stmt.Exec(video.VideoId, video.UpdatedAt, updates)
+	var result, execErr = stmt.Exec(video.VideoId, video.UpdatedAt, updates, revertedTo)
 	if execErr != nil {
 		return execErr
 	}
@@ -419,8 +312,6 @@ func insertPlaylistUpdates(transaction *sql.Tx, video *videoData, columnsToUpdat
 	return nil
 }
 
-func CloseDatabaseConnection() {
-	if _connection != nil {
-		_connection.Close()
-	}
+func (store *Store) Close() error {
+	return store.connection.Close()
 }
@@ -0,0 +1,133 @@
+package netflix
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The merge policies of the fields of videoData, given by their tag "merge".
+const (
+	// The value received replaces the saved one (the default).
+	mergeOverwrite = "overwrite"
+	// An empty value received doesn't replace the saved one.
+	mergeIgnoreEmpty = "ignoreEmpty"
+	// The value received replaces the saved one only when another column is updated.
+	mergeWithOthers = "withOthers"
+	// The field is never compared, it's saved by its own logic.
+	mergeNever = "-"
+)
+
+// A field of videoData saved in a column of the playlist, given by its tag "db".
+type videoField struct {
+	index  int
+	column string
+	merge  string
+}
+
+// The fields of videoData saved in the playlist, in the order of the struct.
+var videoFields = parseVideoFields()
+
+func parseVideoFields() []videoField {
+	var fields []videoField
+	var videoType = reflect.TypeOf(videoData{})
+	for i := 0; i < videoType.NumField(); i++ {
+		var structField = videoType.Field(i)
+		var column = structField.Tag.Get("db")
+		if column == "" {
+			continue
+		}
+
+		var merge = structField.Tag.Get("merge")
+		switch merge {
+		case "":
+			merge = mergeOverwrite
+		case mergeOverwrite, mergeIgnoreEmpty, mergeWithOthers, mergeNever:
+		default:
+			panic(fmt.Sprintf("The merge policy of the field %s is invalid (should be either %s/%s/%s/%s): %s",
+				structField.Name, mergeOverwrite, mergeIgnoreEmpty, mergeWithOthers, mergeNever, merge))
+		}
+		fields = append(fields, videoField{index: i, column: column, merge: merge})
+	}
+	return fields
+}
+
+// Get the columns of the playlist saved from videoData.
+func videoColumns() []string {
+	var columns []string
+	for _, field := range videoFields {
+		columns = append(columns, field.column)
+	}
+	return columns
+}
+
+// Get the pointers to the fields of the video, in the order of videoColumns, to scan a row.
+func videoFieldPointers(video *videoData) []any {
+	var videoValue = reflect.ValueOf(video).Elem()
+	var pointers []any
+	for _, field := range videoFields {
+		pointers = append(pointers, videoValue.Field(field.index).Addr().Interface())
+	}
+	return pointers
+}
+
+// Get the values of the fields of the video, in the order of videoColumns.
+func videoFieldValues(video *videoData) []any {
+	var videoValue = reflect.ValueOf(video).Elem()
+	var values []any
+	for _, field := range videoFields {
+		values = append(values, videoValue.Field(field.index).Interface())
+	}
+	return values
+}
+
+// Compare the saved video to the one received, following the merge policy of each field.
+//
+// Return the columns to update, sorted by name, with their old and new values.
+func diffVideo(savedVideo *videoData, videoToAdd *videoData) ([]string, []any, []any) {
+	var savedValue = reflect.ValueOf(savedVideo).Elem()
+	var newValue = reflect.ValueOf(videoToAdd).Elem()
+
+	var changedFields []videoField
+	var changedWithOthers []videoField
+	for _, field := range videoFields {
+		var saved = savedValue.Field(field.index)
+		var received = newValue.Field(field.index)
+		if field.merge == mergeNever || saved.Interface() == received.Interface() {
+			continue
+		}
+
+		switch field.merge {
+		case mergeIgnoreEmpty:
+			if received.IsZero() {
+				continue
+			}
+		case mergeWithOthers:
+			changedWithOthers = append(changedWithOthers, field)
+			continue
+		}
+		changedFields = append(changedFields, field)
+	}
+	if len(changedFields) > 0 {
+		changedFields = append(changedFields, changedWithOthers...)
+	}
+	sort.Slice(changedFields, func(i, j int) bool {
+		return changedFields[i].column < changedFields[j].column
+	})
+
+	var columns []string
+	var oldValues []any
+	var newValues []any
+	for _, field := range changedFields {
+		columns = append(columns, field.column)
+		oldValues = append(oldValues, savedValue.Field(field.index).Interface())
+		newValues = append(newValues, newValue.Field(field.index).Interface())
+	}
+	return columns, oldValues, newValues
+}
+
+// Make the placeholders of the given number of values, like "?, ?, ?".
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
@@ -0,0 +1,225 @@
+package netflix
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// The formats of the exports of the playlist.
+const (
+	// A CSV file with the columns chosen.
+	exportFormatCSV = "csv"
+	// A JSON object per line, with all the fields.
+	exportFormatNDJSON = "ndjson"
+	// A CSV file of the movies which can be imported in Letterboxd.
+	exportFormatLetterboxd = "letterboxd"
+)
+
+// The values of the column type of the movies, in lower case, like Netflix sends them (the series are "show").
+var movieTypes = []string{"movie"}
+
+func isMovieType(videoType string) bool {
+	for _, movieType := range movieTypes {
+		if strings.ToLower(videoType) == movieType {
+			return true
+		}
+	}
+	return false
+}
+
+// A field of PlaylistVideo which can be exported in CSV, named like in JSON.
+type exportField struct {
+	name  string
+	index int
+}
+
+// The fields which can be exported in CSV, in the order of PlaylistVideo.
+var exportFields = parseExportFields()
+
+func parseExportFields() []exportField {
+	var fields []exportField
+	var videoType = reflect.TypeOf(PlaylistVideo{})
+	for i := 0; i < videoType.NumField(); i++ {
+		var name, _, _ = strings.Cut(videoType.Field(i).Tag.Get("json"), ",")
+		var kind = videoType.Field(i).Type.Kind()
+		if name == "" || name == "-" || kind == reflect.Slice {
+			continue
+		}
+		fields = append(fields, exportField{name: name, index: i})
+	}
+	return fields
+}
+
+// What to export from the playlist, and how.
+type ExportOptions struct {
+	// csv, ndjson or letterboxd.
+	Format string
+	// The fields exported in CSV, like in JSON. Empty for all of them.
+	Columns []string
+	// The videos to export, like the list of the playlist.
+	Query PlaylistQuery
+}
+
+// Read the export options from the parameters: format, columns (separated by commas) and the ones of the list of the playlist.
+func ParseExportOptions(values url.Values) (ExportOptions, error) {
+	var options = ExportOptions{Format: values.Get("format")}
+	if options.Format == "" {
+		options.Format = exportFormatCSV
+	}
+	if columns := values.Get("columns"); columns != "" {
+		options.Columns = strings.Split(columns, ",")
+	}
+
+	var query, queryErr = parsePlaylistQuery(values)
+	if queryErr != nil {
+		return options, queryErr
+	}
+	options.Query = query
+	return options, options.validate()
+}
+
+func (options *ExportOptions) validate() error {
+	switch options.Format {
+	case exportFormatCSV:
+	case exportFormatNDJSON, exportFormatLetterboxd:
+		if len(options.Columns) > 0 {
+			return fmt.Errorf("The columns can only be chosen for the format csv")
+		}
+	default:
+		return fmt.Errorf("The format \"%s\" is invalid (should be either csv/ndjson/letterboxd)", options.Format)
+	}
+	if options.Format == exportFormatLetterboxd && options.Query.Type != "" && isMovieType(options.Query.Type) == false {
+		return fmt.Errorf("Only the movies can be exported to Letterboxd")
+	}
+
+	for _, column := range options.Columns {
+		if _, fieldExists := findExportField(column); fieldExists == false {
+			var names []string
+			for _, field := range exportFields {
+				names = append(names, field.name)
+			}
+			return fmt.Errorf("The column \"%s\" is invalid (should be one of %s)", column, strings.Join(names, "/"))
+		}
+	}
+	return options.Query.validate()
+}
+
+func findExportField(name string) (exportField, bool) {
+	for _, field := range exportFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return exportField{}, false
+}
+
+// Get the file extension and the content type of the format.
+func (options *ExportOptions) FileType() (string, string) {
+	switch options.Format {
+	case exportFormatNDJSON:
+		return ".ndjson", "application/x-ndjson"
+	case exportFormatLetterboxd:
+		return "-letterboxd.csv", "text/csv; charset=utf-8"
+	default:
+		return ".csv", "text/csv; charset=utf-8"
+	}
+}
+
+// Write the videos of the playlist in the given format, one by one as they are read from database.
+//
+// Return the number of videos exported.
+func (store *Store) ExportPlaylist(w io.Writer, options ExportOptions) (int, error) {
+	if validateErr := options.validate(); validateErr != nil {
+		return 0, validateErr
+	}
+	if options.Format == exportFormatLetterboxd {
+		options.Query.moviesOnly = true
+	}
+
+	var sqlQuery, args, queryErr = options.Query.toSQL()
+	if queryErr != nil {
+		return 0, queryErr
+	}
+	var rows, rowsErr = store.connection.Query(sqlQuery, args...)
+	if rowsErr != nil {
+		return 0, rowsErr
+	}
+	defer rows.Close()
+
+	var writeVideo func(video *PlaylistVideo) error
+	var flush = func() error { return nil }
+	switch options.Format {
+	case exportFormatNDJSON:
+		var encoder = json.NewEncoder(w)
+		writeVideo = func(video *PlaylistVideo) error {
+			return encoder.Encode(video)
+		}
+	case exportFormatLetterboxd:
+		//.. Letterboxd finds the movies by their title and directors. There is no column Year, since Netflix only gives the date of availability.
+		var csvWriter = csv.NewWriter(w)
+		if headerErr := csvWriter.Write([]string{"Title", "Directors"}); headerErr != nil {
+			return 0, headerErr
+		}
+		writeVideo = func(video *PlaylistVideo) error {
+			return csvWriter.Write([]string{video.Title, video.Directors})
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	default:
+		var fields = exportFields
+		if len(options.Columns) > 0 {
+			fields = nil
+			for _, column := range options.Columns {
+				var field, _ = findExportField(column)
+				fields = append(fields, field)
+			}
+		}
+		var header []string
+		for _, field := range fields {
+			header = append(header, field.name)
+		}
+		var csvWriter = csv.NewWriter(w)
+		if headerErr := csvWriter.Write(header); headerErr != nil {
+			return 0, headerErr
+		}
+		writeVideo = func(video *PlaylistVideo) error {
+			var videoValue = reflect.ValueOf(video).Elem()
+			var record []string
+			for _, field := range fields {
+				record = append(record, fmt.Sprint(videoValue.Field(field.index).Interface()))
+			}
+			return csvWriter.Write(record)
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	}
+
+	var count = 0
+	for rows.Next() {
+		//.. toSQL gets one more video than the limit, to know if there is a next page.
+		if options.Query.Limit > 0 && count == options.Query.Limit {
+			break
+		}
+		var video, scanErr = scanPlaylistVideo(rows)
+		if scanErr != nil {
+			return count, scanErr
+		}
+		if writeErr := writeVideo(video); writeErr != nil {
+			return count, writeErr
+		}
+		count++
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return count, rowsErr
+	}
+	return count, flush()
+}
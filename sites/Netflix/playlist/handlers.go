@@ -2,16 +2,26 @@ package netflix
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
 	"mylocalhost/logger"
+	dates "mylocalhost/utils/dates"
 	responses "mylocalhost/utils/responses"
+	validation "mylocalhost/utils/validation"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // I just added or removed a movie/serie from my playlist.
 // My Chrome extension intercepted the request and sent the video data to be saved in database.
-func SaveVideoToPlaylistRequestHandler(w http.ResponseWriter, r *http.Request) {
+//
+// With the query parameter dryRun=true, nothing is saved: the result shows what would be changed, to preview it.
+func (store *Store) SaveVideoToPlaylistRequestHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != "POST" {
@@ -19,6 +29,12 @@ func SaveVideoToPlaylistRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var dryRun, dryRunErr = parseDryRun(r)
+	if dryRunErr != nil {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, dryRunErr.Error())
+		return
+	}
+
 	var requestBody, requestBodyErr = io.ReadAll(r.Body)
 	if requestBodyErr != nil {
 		responses.SendErrorResponse(w, http.StatusInternalServerError, requestBodyErr, "Reading POST data")
@@ -29,15 +45,15 @@ func SaveVideoToPlaylistRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var videoData *videoData
-	if parseErr := json.Unmarshal(requestBody, &videoData); parseErr != nil {
-		var body = string(requestBody)
-		logger.WriteError("[Netflix][SaveVideoToPlaylistRequestHandler] Body received:\n%s", body)
-		responses.SendErrorResponse(w, http.StatusBadRequest, parseErr, "Parsing the POST data to JSON")
+	//.. The body is logged so I can see what has changed when Netflix changes the format of its data.
+	var videoData = &videoData{}
+	if decodeErr := validation.Decode(requestBody, videoData); decodeErr != nil {
+		logger.WriteError("[Netflix][SaveVideoToPlaylistRequestHandler] Invalid POST data: %s\nBody received:\n%s", decodeErr.Error(), string(requestBody))
+		responses.SendRequestErrorResponse(w, http.StatusBadRequest, decodeErr, "Decoding the POST data")
 		return
 	}
 
-	var sqlResult = saveVideoToPlaylist(videoData)
+	var sqlResult = store.saveVideoToPlaylist(videoData, dryRun)
 
 	var buffer bytes.Buffer
 	if encodeErr := json.NewEncoder(&buffer).Encode(sqlResult); encodeErr == nil {
@@ -46,3 +62,377 @@ func SaveVideoToPlaylistRequestHandler(w http.ResponseWriter, r *http.Request) {
 		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the SQL result in JSON")
 	}
 }
+
+// Get the videos of my playlist: /netflix/playlist
+//
+// The videos can be filtered, sorted and paginated with the query parameters:
+// type, genre, mood, tag, cast (a part of them), ageAdvised, maxAgeAdvised, status (the current one), inList (true/false),
+// sort (createdAt/updatedAt), order (asc/desc), limit and cursor.
+// When there is a next page, its cursor is sent in the header "X-Next-Cursor".
+//
+// Get a video of my playlist, with all the statuses received: /netflix/playlist/{videoId}
+//
+// With the query parameter at (a date, like 2025-01-01), the playlist or the video is rebuilt as it was at this date.
+// Only the parameter inList can be given with it for the playlist.
+//
+// Get all the changes of a video of my playlist, from the oldest to the newest: /netflix/playlist/{videoId}/history
+//
+// Revert a video of my playlist to an earlier state: POST /netflix/playlist/{videoId}/revert
+// with either {"updateId": the id of an entry of the history} or {"at": a date}, and the query parameter dryRun=true to preview it.
+func (store *Store) PlaylistRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var data any
+	var videoIdPath, subPath, _ = strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/netflix/playlist"), "/"), "/")
+	if subPath != "" && subPath != "history" && subPath != "revert" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "Unknown path")
+		return
+	}
+	if subPath == "revert" && r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
+		return
+	}
+	var at = r.URL.Query().Get("at")
+	if at != "" {
+		if dateErr := validateDate("at", at); dateErr != nil {
+			responses.SendErrorResponse(w, http.StatusBadRequest, dateErr, "Parsing the query parameters")
+			return
+		}
+	}
+	if videoIdPath == "" && at != "" {
+		var query, queryErr = parsePlaylistQuery(r.URL.Query())
+		if queryErr == nil && (query != PlaylistQuery{InList: query.InList}) {
+			queryErr = fmt.Errorf("Only the inList can be given with the at")
+		}
+		if queryErr != nil {
+			responses.SendErrorResponse(w, http.StatusBadRequest, queryErr, "Parsing the query parameters")
+			return
+		}
+		var videos, videosErr = store.GetPlaylistAt(at, query.InList)
+		if videosErr != nil {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, videosErr, "Rebuilding the playlist from database")
+			return
+		}
+		data = videos
+	} else if videoIdPath == "" {
+		var query, queryErr = parsePlaylistQuery(r.URL.Query())
+		if queryErr != nil {
+			responses.SendErrorResponse(w, http.StatusBadRequest, queryErr, "Parsing the query parameters")
+			return
+		}
+		var videos, nextCursor, videosErr = store.GetPlaylist(query)
+		if videosErr != nil {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, videosErr, "Getting the playlist from database")
+			return
+		}
+		if nextCursor != "" {
+			w.Header().Set("X-Next-Cursor", nextCursor)
+		}
+		data = videos
+	} else {
+		var videoId, convErr = strconv.ParseInt(videoIdPath, 10, 64)
+		if convErr != nil {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoId is not a integer")
+			return
+		}
+		var videoErr error
+		if subPath == "revert" {
+			var revertResult, statusCode, revertErr = store.revertVideoFromRequest(r, videoId)
+			if revertErr != nil {
+				logger.WriteError("[Netflix][PlaylistRequestHandler] Revert of the video %d: %s", videoId, revertErr.Error())
+				responses.SendRequestErrorResponse(w, statusCode, revertErr, "Reverting the video")
+				return
+			}
+			data = revertResult
+		} else if subPath == "history" {
+			data, videoErr = store.GetPlaylistHistory(videoId)
+		} else if at != "" {
+			data, videoErr = store.GetPlaylistVideoAt(videoId, at)
+		} else {
+			data, videoErr = store.GetPlaylistVideo(videoId)
+		}
+		if videoErr != nil {
+			if videoErr == sql.ErrNoRows && at != "" && subPath == "" {
+				responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The video wasn't in the playlist at this date")
+			} else if videoErr == sql.ErrNoRows {
+				responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The video isn't in the playlist")
+			} else {
+				responses.SendErrorResponse(w, http.StatusInternalServerError, videoErr, "Getting the video from database")
+			}
+			return
+		}
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(data); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		w.Header().Del("X-Next-Cursor")
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the playlist in JSON")
+	}
+}
+
+// Revert the video with the target sent in the POST data. Return the status code of the error.
+func (store *Store) revertVideoFromRequest(r *http.Request, videoId int64) (*RevertResult, int, error) {
+	var dryRun, dryRunErr = parseDryRun(r)
+	if dryRunErr != nil {
+		return nil, http.StatusBadRequest, dryRunErr
+	}
+	var target = RevertTarget{}
+	if statusCode, decodeErr := validation.DecodeRequestBody(r, &target); decodeErr != nil {
+		return nil, statusCode, decodeErr
+	}
+	if validateErr := target.validate(); validateErr != nil {
+		return nil, http.StatusBadRequest, validateErr
+	}
+
+	var result, revertErr = store.RevertVideo(videoId, target, dryRun)
+	if revertErr == sql.ErrNoRows {
+		return nil, http.StatusNotFound, fmt.Errorf("The video or the entry of its history wasn't found")
+	} else if revertErr != nil {
+		return nil, http.StatusInternalServerError, revertErr
+	}
+	return result, http.StatusOK, nil
+}
+
+// Get the query parameter dryRun: true to preview the changes without saving them.
+func parseDryRun(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("dryRun") {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	default:
+		return false, fmt.Errorf("The dryRun is invalid (should be either true/false)")
+	}
+}
+
+func parsePlaylistQuery(values url.Values) (PlaylistQuery, error) {
+	var query = PlaylistQuery{
+		Type:   values.Get("type"),
+		Genre:  values.Get("genre"),
+		Mood:   values.Get("mood"),
+		Tag:    values.Get("tag"),
+		Cast:   values.Get("cast"),
+		Status: values.Get("status"),
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, fmt.Errorf("The order is invalid (should be either asc/desc)")
+	}
+
+	switch values.Get("inList") {
+	case "":
+	case "true", "false":
+		var inList = values.Get("inList") == "true"
+		query.InList = &inList
+	default:
+		return query, fmt.Errorf("The inList is invalid (should be either true/false)")
+	}
+
+	for name, target := range map[string]**int{"ageAdvised": &query.AgeAdvised, "maxAgeAdvised": &query.MaxAgeAdvised} {
+		if value := values.Get(name); value != "" {
+			var intValue, convErr = strconv.Atoi(value)
+			if convErr != nil {
+				return query, fmt.Errorf("The %s is not a integer", name)
+			}
+			*target = &intValue
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		var limitValue, convErr = strconv.Atoi(limit)
+		if convErr != nil {
+			return query, fmt.Errorf("The limit is not a integer")
+		}
+		query.Limit = limitValue
+	}
+
+	var validateErr = query.validate()
+	return query, validateErr
+}
+
+// Get the recent changes of all the videos of my playlist, from the newest to the oldest.
+//
+// Query parameters: column (a field of the videos, like synopsis), since (a date, like 2024-05), limit (100 by default).
+func (store *Store) RecentChangesRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var values = r.URL.Query()
+	var query = RecentChangesQuery{Column: values.Get("column"), Since: values.Get("since"), Limit: 100}
+	if limit := values.Get("limit"); limit != "" {
+		var limitValue, convErr = strconv.Atoi(limit)
+		if convErr != nil {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The limit is not a integer")
+			return
+		}
+		query.Limit = limitValue
+	}
+	if validateErr := query.validate(); validateErr != nil {
+		responses.SendErrorResponse(w, http.StatusBadRequest, validateErr, "Parsing the query parameters")
+		return
+	}
+
+	var changes, changesErr = store.GetRecentPlaylistChanges(query)
+	if changesErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, changesErr, "Getting the recent changes from database")
+		return
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(changes); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the recent changes in JSON")
+	}
+}
+
+// Browse the people, the genres, the moods or the tags of my playlist, from the most linked to the least:
+// /netflix/people, /netflix/genres, /netflix/moods, /netflix/tags
+//
+// Query parameters: q (a part of the name), role (cast/creator/director/writer, only for the people).
+//
+// Get a person, a genre, a mood or a tag with the videos it's linked to: /netflix/people/{id}, /netflix/genres/{id}...
+func (store *Store) BrowseRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var data any
+	var tableName, idPath, _ = strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/netflix/"), "/"), "/")
+	if _, tableExists := browsableTables[tableName]; tableExists == false || strings.Contains(idPath, "/") {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "Unknown path")
+		return
+	}
+	if idPath == "" {
+		var values = r.URL.Query()
+		var query = LinkedNamesQuery{Table: tableName, Search: values.Get("q"), Role: values.Get("role")}
+		if validateErr := query.validate(); validateErr != nil {
+			responses.SendErrorResponse(w, http.StatusBadRequest, validateErr, "Parsing the query parameters")
+			return
+		}
+		var names, namesErr = store.GetLinkedNames(query)
+		if namesErr != nil {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, namesErr, "Getting the "+tableName+" from database")
+			return
+		}
+		data = names
+	} else {
+		var id, convErr = strconv.ParseInt(idPath, 10, 64)
+		if convErr != nil {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The id is not a integer")
+			return
+		}
+		var name, nameErr = store.GetLinkedName(tableName, id)
+		if nameErr != nil {
+			if nameErr == sql.ErrNoRows {
+				responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "Nothing was found for this id")
+			} else {
+				responses.SendErrorResponse(w, http.StatusInternalServerError, nameErr, "Getting the "+tableName+" from database")
+			}
+			return
+		}
+		data = name
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(data); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the "+tableName+" in JSON")
+	}
+}
+
+// Get the videos in my playlist which will be available in the future, grouped by week: /netflix/upcoming
+func (store *Store) UpcomingRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var weeks, weeksErr = store.GetUpcomingTitles(time.Now())
+	if weeksErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, weeksErr, "Getting the upcoming videos from database")
+		return
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(weeks); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the upcoming videos in JSON")
+	}
+}
+
+// The iCalendar feed of the dates of availability of the videos in my playlist, to subscribe to in my calendar app: /netflix/calendar.ics
+func (store *Store) CalendarRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var buffer bytes.Buffer
+	if calendarErr := store.WriteCalendar(&buffer); calendarErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		responses.SendErrorResponse(w, http.StatusInternalServerError, calendarErr, "Getting the calendar from database")
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	buffer.WriteTo(w)
+}
+
+// Compare my playlist between two dates: the videos added, removed and changed.
+//
+// Query parameters: from and to (dates, like 2025-01-01), to is now by default.
+func (store *Store) PlaylistDiffRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var values = r.URL.Query()
+	var from, to = values.Get("from"), values.Get("to")
+	if to == "" {
+		to = dates.NowToString()
+	}
+	var dateErr = validateDate("from", from)
+	if dateErr == nil {
+		dateErr = validateDate("to", to)
+	}
+	if dateErr != nil {
+		responses.SendErrorResponse(w, http.StatusBadRequest, dateErr, "Parsing the query parameters")
+		return
+	}
+	if from > to {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The from must be before the to")
+		return
+	}
+
+	var diff, diffErr = store.GetPlaylistDiff(from, to)
+	if diffErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, diffErr, "Comparing the playlist from database")
+		return
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(diff); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the diff of the playlist in JSON")
+	}
+}
+
+// Export the videos of my playlist: /netflix/export
+//
+// Query parameters: format (csv/ndjson/letterboxd), columns (the fields exported in csv, separated by commas),
+// and the filters of /netflix/playlist. The file is streamed while the videos are read.
+func (store *Store) ExportRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var options, optionsErr = ParseExportOptions(r.URL.Query())
+	if optionsErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		responses.SendErrorResponse(w, http.StatusBadRequest, optionsErr, "Parsing the query parameters")
+		return
+	}
+
+	var extension, contentType = options.FileType()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\"netflix-playlist"+extension+"\"")
+
+	//.. Once the export has started, the status can't be changed anymore, so the errors are only logged.
+	if count, exportErr := store.ExportPlaylist(w, options); exportErr != nil {
+		logger.WriteError("[Netflix][ExportRequestHandler] Export stopped after %d videos: %s", count, exportErr.Error())
+	}
+}
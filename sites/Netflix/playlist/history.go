@@ -0,0 +1,160 @@
+package netflix
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A change of a column of a video of the playlist, read from the table playlist_updates.
+type PlaylistChange struct {
+	// The id of the entry of the history, which can be given to revert the video.
+	UpdateId int64 `json:"updateId"`
+	VideoId  int64 `json:"videoId"`
+	// The current title of the video. Only sent in the recent changes.
+	Title string `json:"title,omitempty"`
+	// The field of PlaylistVideo matching the column changed.
+	Column    string `json:"column"`
+	OldValue  any    `json:"oldValue"`
+	NewValue  any    `json:"newValue"`
+	UpdatedAt string `json:"updatedAt"`
+	// When the change is a revert, the date of the state restored.
+	RevertedTo string `json:"revertedTo,omitempty"`
+}
+
+// The columns of the playlist, by the name of their field in PlaylistVideo.
+var playlistFields = map[string]string{
+	"type":                  "type",
+	"title":                 "title",
+	"status":                "status",
+	"casting":               "casting",
+	"creators":              "creators",
+	"directors":             "directors",
+	"writers":               "writers",
+	"genres":                "genres",
+	"mood":                  "mood",
+	"tags":                  "tags",
+	"ageAdvised":            "age_advised",
+	"ageAdvisedReason":      "age_advised_reason",
+	"synopsis":              "synopsis",
+	"seasonCount":           "season_count",
+	"numSeasonLabel":        "num_season_label",
+	"episodeCount":          "episode_count",
+	"durationSec":           "duration_sec",
+	"availabilityStartTime": "availability_starttime",
+	"dataFrom":              "_data_from",
+}
+
+// Get the name of the field of the given column, or the column itself if it has no field.
+func fieldOfColumn(column string) string {
+	for field, fieldColumn := range playlistFields {
+		if fieldColumn == column {
+			return field
+		}
+	}
+	return column
+}
+
+// The filters of the recent changes of the playlist.
+type RecentChangesQuery struct {
+	// A field of PlaylistVideo. Empty for every column.
+	Column string
+	// The changes made since this date, which is compared on its length, so "2024", "2024-05" or "2024-05-17" can be used.
+	Since string
+	// The maximum number of changes to get. 0 for all of them.
+	Limit int
+}
+
+func (query *RecentChangesQuery) validate() error {
+	if _, keyExists := playlistFields[query.Column]; query.Column != "" && keyExists == false {
+		var fields []string
+		for field := range playlistFields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		return fmt.Errorf("The column \"%s\" is invalid (should be one of %s)", query.Column, strings.Join(fields, "/"))
+	}
+	if query.Limit < 0 {
+		return fmt.Errorf("The limit can't be negative")
+	}
+	return nil
+}
+
+// Every change of the playlist, one row per column changed, with its parsed old and new values.
+const playlistChangesSelect = `SELECT playlist_updates.rowid, playlist_updates.video_id, COALESCE(playlist.title, ''), json_extract(change.value, '$.column'),
+	json_extract(change.value, '$.oldValue'), json_extract(change.value, '$.newValue'), playlist_updates.updated_at,
+	playlist_updates.reverted_to
+	FROM playlist_updates
+	INNER JOIN json_each(playlist_updates.updates) AS change
+	LEFT JOIN playlist ON playlist.video_id = playlist_updates.video_id`
+
+// Get all the changes of the given video, from the oldest to the newest.
+//
+// Return sql.ErrNoRows if the video isn't in the playlist.
+func (store *Store) GetPlaylistHistory(videoId int64) ([]PlaylistChange, error) {
+	var rowid int64
+	if scanErr := store.connection.QueryRow("SELECT rowid FROM playlist WHERE video_id = ?;", videoId).Scan(&rowid); scanErr != nil {
+		return nil, scanErr
+	}
+
+	var changes, changesErr = store.queryPlaylistChanges(playlistChangesSelect+` WHERE playlist_updates.video_id = ?
+		ORDER BY playlist_updates.updated_at, playlist_updates.rowid, change.key;`, videoId)
+	if changesErr != nil {
+		return nil, changesErr
+	}
+	for i := range changes {
+		changes[i].Title = ""
+	}
+	return changes, nil
+}
+
+// Get the changes of all the videos matching the given query, from the newest to the oldest.
+func (store *Store) GetRecentPlaylistChanges(query RecentChangesQuery) ([]PlaylistChange, error) {
+	if validateErr := query.validate(); validateErr != nil {
+		return nil, validateErr
+	}
+
+	var conditions []string
+	var args []any
+	if query.Column != "" {
+		conditions = append(conditions, "json_extract(change.value, '$.column') = ?")
+		args = append(args, playlistFields[query.Column])
+	}
+	if query.Since != "" {
+		conditions = append(conditions, "playlist_updates.updated_at >= ?")
+		args = append(args, query.Since)
+	}
+
+	var sqlQuery = playlistChangesSelect
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY playlist_updates.updated_at DESC, playlist_updates.rowid DESC, change.key"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+	return store.queryPlaylistChanges(sqlQuery+";", args...)
+}
+
+func (store *Store) queryPlaylistChanges(sqlQuery string, args ...any) ([]PlaylistChange, error) {
+	var rows, queryErr = store.connection.Query(sqlQuery, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var changes = []PlaylistChange{}
+	for rows.Next() {
+		var change = PlaylistChange{}
+		var column sql.NullString
+		if scanErr := rows.Scan(&change.UpdateId, &change.VideoId, &change.Title, &column, &change.OldValue, &change.NewValue,
+			&change.UpdatedAt, &change.RevertedTo); scanErr != nil {
+			return nil, scanErr
+		}
+		change.Column = fieldOfColumn(column.String)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
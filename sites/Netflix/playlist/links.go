@@ -0,0 +1,298 @@
+package netflix
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// The separator of the names in the columns casting, creators, directors, writers, genres, mood and tags.
+const listSeparator = ","
+
+// A table of names linked to the videos of the playlist.
+type nameTable struct {
+	// The table of the names.
+	table string
+	// The table linking the names to the videos, and its column of the id of the name.
+	linkTable string
+	idColumn  string
+}
+
+var (
+	peopleTable = nameTable{table: "people", linkTable: "video_people", idColumn: "person_id"}
+	genresTable = nameTable{table: "genres", linkTable: "video_genres", idColumn: "genre_id"}
+	moodsTable  = nameTable{table: "moods", linkTable: "video_moods", idColumn: "mood_id"}
+	tagsTable   = nameTable{table: "tags", linkTable: "video_tags", idColumn: "tag_id"}
+)
+
+// The tables which can be browsed, by the name used in the requests.
+var browsableTables = map[string]nameTable{
+	"people": peopleTable,
+	"genres": genresTable,
+	"moods":  moodsTable,
+	"tags":   tagsTable,
+}
+
+// A column of the playlist whose names are linked to the video.
+type linkedColumn struct {
+	column string
+	names  nameTable
+	// The role of the people. Empty for the other tables.
+	role string
+}
+
+var linkedColumns = []linkedColumn{
+	{column: "casting", names: peopleTable, role: "cast"},
+	{column: "creators", names: peopleTable, role: "creator"},
+	{column: "directors", names: peopleTable, role: "director"},
+	{column: "writers", names: peopleTable, role: "writer"},
+	{column: "genres", names: genresTable},
+	{column: "mood", names: moodsTable},
+	{column: "tags", names: tagsTable},
+}
+
+// Get the linked columns of the video, with their value.
+func videoLinkValues(video *videoData) map[string]string {
+	return map[string]string{
+		"casting":   video.Casting,
+		"creators":  video.Creators,
+		"directors": video.Directors,
+		"writers":   video.Writers,
+		"genres":    video.Genres,
+		"mood":      video.Mood,
+		"tags":      video.Tags,
+	}
+}
+
+// Split the value of a linked column into its names, without the duplicates.
+func splitList(value string) []string {
+	var names []string
+	var seen = make(map[string]bool)
+	for _, name := range strings.Split(value, listSeparator) {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// Replace the names linked to the video by the ones of the given columns. The columns not given are left untouched.
+func syncVideoLinks(transaction *sql.Tx, videoId int64, values map[string]string) error {
+	//.. The names unlinked from the video, by table, which are deleted if they are no longer linked to any video.
+	var unlinkedIds = make(map[nameTable][]int64)
+	for _, linked := range linkedColumns {
+		var value, keyExists = values[linked.column]
+		if keyExists == false {
+			continue
+		}
+
+		var condition = " WHERE video_id = ?"
+		var conditionArgs = []any{videoId}
+		if linked.role != "" {
+			condition += " AND role = ?"
+			conditionArgs = append(conditionArgs, linked.role)
+		}
+		var linkedIds, idsErr = queryIds(transaction, "SELECT "+linked.names.idColumn+" FROM "+linked.names.linkTable+condition+";", conditionArgs...)
+		if idsErr != nil {
+			return idsErr
+		}
+		unlinkedIds[linked.names] = append(unlinkedIds[linked.names], linkedIds...)
+		if _, deleteErr := transaction.Exec("DELETE FROM "+linked.names.linkTable+condition+";", conditionArgs...); deleteErr != nil {
+			return deleteErr
+		}
+
+		for _, name := range splitList(value) {
+			if _, insertErr := transaction.Exec("INSERT OR IGNORE INTO "+linked.names.table+"(name) VALUES(?);", name); insertErr != nil {
+				return insertErr
+			}
+			var nameId int64
+			if scanErr := transaction.QueryRow("SELECT id FROM "+linked.names.table+" WHERE name = ?;", name).Scan(&nameId); scanErr != nil {
+				return scanErr
+			}
+
+			var columns = "video_id, " + linked.names.idColumn
+			var placeholders = "?, ?"
+			var args = []any{videoId, nameId}
+			if linked.role != "" {
+				columns += ", role"
+				placeholders += ", ?"
+				args = append(args, linked.role)
+			}
+			if _, linkErr := transaction.Exec("INSERT INTO "+linked.names.linkTable+"("+columns+") VALUES("+placeholders+");", args...); linkErr != nil {
+				return linkErr
+			}
+		}
+	}
+
+	for names, ids := range unlinkedIds {
+		for _, id := range ids {
+			if _, deleteErr := transaction.Exec("DELETE FROM "+names.table+" WHERE id = ? AND NOT EXISTS (SELECT 1 FROM "+names.linkTable+" WHERE "+names.idColumn+" = ?);", id, id); deleteErr != nil {
+				return deleteErr
+			}
+		}
+	}
+	return nil
+}
+
+func queryIds(transaction *sql.Tx, query string, args ...any) ([]int64, error) {
+	var rows, queryErr = transaction.Query(query, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if scanErr := rows.Scan(&id); scanErr != nil {
+			return nil, scanErr
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// A person, a genre, a mood or a tag, with the number of videos it's linked to.
+type LinkedName struct {
+	Id         int64  `json:"id"`
+	Name       string `json:"name"`
+	TitleCount int    `json:"titleCount"`
+	// The videos linked to the name. Only sent with a single name.
+	Titles []LinkedTitle `json:"titles,omitempty"`
+}
+
+// A video linked to a name.
+type LinkedTitle struct {
+	VideoId int64  `json:"videoId"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	// The roles of the person in the video (cast, creator, director, writer). Only sent for the people.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// The roles of the people.
+var personRoles = []string{"cast", "creator", "director", "writer"}
+
+// The filters of the names of a table.
+type LinkedNamesQuery struct {
+	// people, genres, moods or tags.
+	Table string
+	// A part of the name, case insensitive.
+	Search string
+	// Only the people with this role (cast, creator, director or writer). Empty for every role.
+	Role string
+}
+
+func (query *LinkedNamesQuery) validate() error {
+	var names, tableExists = browsableTables[query.Table]
+	if tableExists == false {
+		return fmt.Errorf("The table \"%s\" is invalid (should be either people/genres/moods/tags)", query.Table)
+	}
+	if query.Role != "" {
+		if names != peopleTable {
+			return fmt.Errorf("Only the people have a role")
+		}
+		if isPersonRole(query.Role) == false {
+			return fmt.Errorf("The role \"%s\" is invalid (should be either %s)", query.Role, strings.Join(personRoles, "/"))
+		}
+	}
+	return nil
+}
+
+// Get the names matching the given query, from the most linked to the least.
+func (store *Store) GetLinkedNames(query LinkedNamesQuery) ([]LinkedName, error) {
+	if validateErr := query.validate(); validateErr != nil {
+		return nil, validateErr
+	}
+	var names = browsableTables[query.Table]
+
+	var conditions []string
+	var args []any
+	if query.Search != "" {
+		conditions = append(conditions, "instr(lower(names.name), lower(?)) > 0")
+		args = append(args, query.Search)
+	}
+	if query.Role != "" {
+		conditions = append(conditions, "links.role = ?")
+		args = append(args, query.Role)
+	}
+
+	var sqlQuery = "SELECT names.id, names.name, COUNT(DISTINCT links.video_id) FROM " + names.table + " AS names" +
+		" INNER JOIN " + names.linkTable + " AS links ON links." + names.idColumn + " = names.id"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " GROUP BY names.id ORDER BY COUNT(DISTINCT links.video_id) DESC, names.name COLLATE NOCASE;"
+
+	var rows, queryErr = store.connection.Query(sqlQuery, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var linkedNames = []LinkedName{}
+	for rows.Next() {
+		var linkedName = LinkedName{}
+		if scanErr := rows.Scan(&linkedName.Id, &linkedName.Name, &linkedName.TitleCount); scanErr != nil {
+			return nil, scanErr
+		}
+		linkedNames = append(linkedNames, linkedName)
+	}
+	return linkedNames, rows.Err()
+}
+
+// Get a name of the given table (people, genres, moods or tags), with the videos it's linked to in the order they were added.
+//
+// Return sql.ErrNoRows if the name doesn't exist.
+func (store *Store) GetLinkedName(tableName string, id int64) (*LinkedName, error) {
+	var names, tableExists = browsableTables[tableName]
+	if tableExists == false {
+		return nil, fmt.Errorf("The table \"%s\" is invalid (should be either people/genres/moods/tags)", tableName)
+	}
+
+	var linkedName = &LinkedName{Id: id, Titles: []LinkedTitle{}}
+	if scanErr := store.connection.QueryRow("SELECT name FROM "+names.table+" WHERE id = ?;", id).Scan(&linkedName.Name); scanErr != nil {
+		return nil, scanErr
+	}
+
+	var roles = "''"
+	if names == peopleTable {
+		roles = "group_concat(links.role)"
+	}
+	var rows, queryErr = store.connection.Query("SELECT playlist.video_id, playlist.type, playlist.title, "+roles+" FROM "+names.linkTable+" AS links"+
+		" INNER JOIN playlist ON playlist.video_id = links.video_id WHERE links."+names.idColumn+" = ?"+
+		" GROUP BY playlist.video_id ORDER BY playlist.rowid;", id)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var title = LinkedTitle{}
+		var titleRoles string
+		if scanErr := rows.Scan(&title.VideoId, &title.Type, &title.Title, &titleRoles); scanErr != nil {
+			return nil, scanErr
+		}
+		if titleRoles != "" {
+			title.Roles = strings.Split(titleRoles, ",")
+		}
+		linkedName.Titles = append(linkedName.Titles, title)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+	linkedName.TitleCount = len(linkedName.Titles)
+	return linkedName, nil
+}
+
+func isPersonRole(role string) bool {
+	for _, personRole := range personRoles {
+		if personRole == role {
+			return true
+		}
+	}
+	return false
+}
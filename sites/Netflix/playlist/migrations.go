@@ -0,0 +1,230 @@
+package netflix
+
+import (
+	"database/sql"
+	utils "mylocalhost/utils/database"
+	"strings"
+)
+
+// The migrations of the schema of the database, in order. A new migration must be appended at the end.
+var migrations = []utils.Migration{
+	{
+		Version:     1,
+		Description: "Create the playlist and playlist_updates tables",
+		Script: `
+		CREATE TABLE IF NOT EXISTS "playlist" (
+			"video_id"	INTEGER NOT NULL CHECK("video_id" > 0) UNIQUE,
+			"type"	TEXT NOT NULL DEFAULT '',
+			"title"	TEXT NOT NULL CHECK("title" != ''),
+			"status"	TEXT NOT NULL CHECK("status" != ''),
+			"casting"	TEXT NOT NULL DEFAULT '',
+			"creators"	TEXT NOT NULL DEFAULT '',
+			"directors"	TEXT NOT NULL DEFAULT '',
+			"writers"	TEXT NOT NULL DEFAULT '',
+			"genres"	TEXT NOT NULL DEFAULT '',
+			"mood"	TEXT NOT NULL DEFAULT '',
+			"tags"	TEXT NOT NULL DEFAULT '',
+			"age_advised"	INTEGER NOT NULL DEFAULT 0,
+			"age_advised_reason"	TEXT NOT NULL DEFAULT '',
+			"synopsis"	TEXT NOT NULL DEFAULT '',
+			"season_count"	INTEGER NOT NULL DEFAULT 0,
+			"num_season_label"	TEXT NOT NULL DEFAULT '',
+			"episode_count"	INTEGER NOT NULL DEFAULT 0,
+			"duration_sec"	INTEGER NOT NULL DEFAULT 0,
+			"availability_starttime"	TEXT NOT NULL DEFAULT '',
+			"_data_from"	TEXT NOT NULL DEFAULT '',
+			"created_at"	TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime')),
+			"updated_at"	TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE TABLE IF NOT EXISTS "playlist_updates" (
+			"video_id"	INTEGER NOT NULL,
+			"updated_at"	TEXT NOT NULL,
+			"updates"	TEXT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS "idx_playlist_video_id" ON "playlist" ("video_id");`,
+	},
+	{
+		Version:     2,
+		Description: "Move the concatenated statuses to the playlist_status_events table, and learn their actions in the playlist_statuses table",
+		Script: `
+		CREATE TABLE "playlist_statuses" (
+			"status"	TEXT NOT NULL PRIMARY KEY,
+			"action"	TEXT NOT NULL CHECK("action" IN ('add', 'remove', 'unknown')));
+
+		CREATE TABLE "playlist_status_events" (
+			"video_id"	INTEGER NOT NULL,
+			"status"	TEXT NOT NULL CHECK("status" != ''),
+			"action"	TEXT NOT NULL CHECK("action" IN ('add', 'remove', 'unknown')),
+			"occurred_at"	TEXT NOT NULL,
+			FOREIGN KEY("video_id") REFERENCES "playlist"("video_id") ON DELETE CASCADE ON UPDATE CASCADE);
+
+		CREATE INDEX "idx_playlist_status_events_video_id" ON "playlist_status_events" ("video_id", "occurred_at");`,
+		Run: migrateConcatenatedStatuses,
+	},
+	{
+		Version:     3,
+		Description: "Create the people, genres, moods and tags tables, linked to the videos of the playlist",
+		Script: `
+		CREATE TABLE "people" (
+			"id"	INTEGER NOT NULL PRIMARY KEY,
+			"name"	TEXT NOT NULL CHECK("name" != '') UNIQUE COLLATE NOCASE);
+
+		CREATE TABLE "video_people" (
+			"video_id"	INTEGER NOT NULL,
+			"person_id"	INTEGER NOT NULL,
+			"role"	TEXT NOT NULL CHECK("role" IN ('cast', 'creator', 'director', 'writer')),
+			PRIMARY KEY("video_id", "person_id", "role"),
+			FOREIGN KEY("video_id") REFERENCES "playlist"("video_id") ON DELETE CASCADE ON UPDATE CASCADE,
+			FOREIGN KEY("person_id") REFERENCES "people"("id") ON DELETE CASCADE);
+
+		CREATE INDEX "idx_video_people_person_id" ON "video_people" ("person_id");
+
+		CREATE TABLE "genres" (
+			"id"	INTEGER NOT NULL PRIMARY KEY,
+			"name"	TEXT NOT NULL CHECK("name" != '') UNIQUE COLLATE NOCASE);
+
+		CREATE TABLE "video_genres" (
+			"video_id"	INTEGER NOT NULL,
+			"genre_id"	INTEGER NOT NULL,
+			PRIMARY KEY("video_id", "genre_id"),
+			FOREIGN KEY("video_id") REFERENCES "playlist"("video_id") ON DELETE CASCADE ON UPDATE CASCADE,
+			FOREIGN KEY("genre_id") REFERENCES "genres"("id") ON DELETE CASCADE);
+
+		CREATE INDEX "idx_video_genres_genre_id" ON "video_genres" ("genre_id");
+
+		CREATE TABLE "moods" (
+			"id"	INTEGER NOT NULL PRIMARY KEY,
+			"name"	TEXT NOT NULL CHECK("name" != '') UNIQUE COLLATE NOCASE);
+
+		CREATE TABLE "video_moods" (
+			"video_id"	INTEGER NOT NULL,
+			"mood_id"	INTEGER NOT NULL,
+			PRIMARY KEY("video_id", "mood_id"),
+			FOREIGN KEY("video_id") REFERENCES "playlist"("video_id") ON DELETE CASCADE ON UPDATE CASCADE,
+			FOREIGN KEY("mood_id") REFERENCES "moods"("id") ON DELETE CASCADE);
+
+		CREATE INDEX "idx_video_moods_mood_id" ON "video_moods" ("mood_id");
+
+		CREATE TABLE "tags" (
+			"id"	INTEGER NOT NULL PRIMARY KEY,
+			"name"	TEXT NOT NULL CHECK("name" != '') UNIQUE COLLATE NOCASE);
+
+		CREATE TABLE "video_tags" (
+			"video_id"	INTEGER NOT NULL,
+			"tag_id"	INTEGER NOT NULL,
+			PRIMARY KEY("video_id", "tag_id"),
+			FOREIGN KEY("video_id") REFERENCES "playlist"("video_id") ON DELETE CASCADE ON UPDATE CASCADE,
+			FOREIGN KEY("tag_id") REFERENCES "tags"("id") ON DELETE CASCADE);
+
+		CREATE INDEX "idx_video_tags_tag_id" ON "video_tags" ("tag_id");`,
+		Run: backfillVideoLinks,
+	},
+	{
+		Version:     4,
+		Description: "Add the column reverted_to to the playlist_updates table",
+		Script: `
+		ALTER TABLE "playlist_updates" ADD COLUMN "reverted_to" TEXT NOT NULL DEFAULT '';`,
+	},
+}
+
+// Split the statuses concatenated in the column "status" before the table playlist_status_events, into events.
+//
+// Only the date of the first status is known (when the video was inserted). The dates of the next ones were never saved,
+// so they get the date of the last update of the video.
+func splitConcatenatedStatus(status string, createdAt string, updatedAt string) []StatusEvent {
+	const separator = "\r\n\r\n"
+
+	var events []StatusEvent
+	for i, part := range strings.Split(status, separator) {
+		if part == "" {
+			continue
+		}
+		var occurredAt = createdAt
+		if i > 0 && updatedAt != "" {
+			occurredAt = updatedAt
+		}
+		events = append(events, StatusEvent{Status: part, OccurredAt: occurredAt})
+	}
+	return events
+}
+
+// The migration of the concatenated statuses to the table playlist_status_events.
+// The column "status" keeps only the current status.
+func migrateConcatenatedStatuses(transaction *sql.Tx) error {
+	var rows, queryErr = transaction.Query("SELECT video_id, status, created_at, updated_at FROM playlist;")
+	if queryErr != nil {
+		return queryErr
+	}
+	type videoStatus struct {
+		videoId int64
+		events  []StatusEvent
+	}
+	var videoStatuses []videoStatus
+	for rows.Next() {
+		var videoId int64
+		var status, createdAt, updatedAt string
+		if scanErr := rows.Scan(&videoId, &status, &createdAt, &updatedAt); scanErr != nil {
+			rows.Close()
+			return scanErr
+		}
+		videoStatuses = append(videoStatuses, videoStatus{videoId: videoId, events: splitConcatenatedStatus(status, createdAt, updatedAt)})
+	}
+	rows.Close()
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return rowsErr
+	}
+
+	//.. The first statuses are learned before, so a status which is the first one of another video isn't unknown.
+	for _, videoStatus := range videoStatuses {
+		if len(videoStatus.events) > 0 {
+			if _, learnErr := statusAction(transaction, videoStatus.events[0].Status, true); learnErr != nil {
+				return learnErr
+			}
+		}
+	}
+	for _, videoStatus := range videoStatuses {
+		if len(videoStatus.events) == 0 {
+			continue
+		}
+		for i, event := range videoStatus.events {
+			if insertErr := insertStatusEvent(transaction, videoStatus.videoId, event.Status, event.OccurredAt, i == 0); insertErr != nil {
+				return insertErr
+			}
+		}
+		var currentStatus = videoStatus.events[len(videoStatus.events)-1].Status
+		if _, execErr := transaction.Exec("UPDATE playlist SET status = ? WHERE video_id = ?;", currentStatus, videoStatus.videoId); execErr != nil {
+			return execErr
+		}
+	}
+	return nil
+}
+
+// Link the videos already in the playlist to their people, genres, moods and tags.
+func backfillVideoLinks(transaction *sql.Tx) error {
+	var rows, queryErr = transaction.Query("SELECT video_id, casting, creators, directors, writers, genres, mood, tags FROM playlist;")
+	if queryErr != nil {
+		return queryErr
+	}
+	var videos []*videoData
+	for rows.Next() {
+		var video = &videoData{}
+		if scanErr := rows.Scan(&video.VideoId, &video.Casting, &video.Creators, &video.Directors, &video.Writers, &video.Genres, &video.Mood, &video.Tags); scanErr != nil {
+			rows.Close()
+			return scanErr
+		}
+		videos = append(videos, video)
+	}
+	rows.Close()
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return rowsErr
+	}
+
+	for _, video := range videos {
+		if syncErr := syncVideoLinks(transaction, video.VideoId, videoLinkValues(video)); syncErr != nil {
+			return syncErr
+		}
+	}
+	return nil
+}
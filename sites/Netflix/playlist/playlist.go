@@ -0,0 +1,118 @@
+package netflix
+
+// A video of my playlist, as sent by the read API.
+type PlaylistVideo struct {
+	Rowid   int64  `json:"-"`
+	VideoId int64  `json:"videoId"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	// The last status received.
+	Status string `json:"status"`
+	// If the video is in my playlist now, i.e. it wasn't removed by the last status.
+	InList bool `json:"inList"`
+	// When I added the video to my playlist for the first time.
+	FirstAddedAt string `json:"firstAddedAt"`
+	// All the statuses received, from the oldest to the newest. Only sent with a single video.
+	StatusEvents []StatusEvent `json:"statusEvents,omitempty"`
+
+	Casting   string `json:"casting"`
+	Creators  string `json:"creators"`
+	Directors string `json:"directors"`
+	Writers   string `json:"writers"`
+
+	Genres string `json:"genres"`
+	Mood   string `json:"mood"`
+	Tags   string `json:"tags"`
+
+	AgeAdvised       int    `json:"ageAdvised"`
+	AgeAdvisedReason string `json:"ageAdvisedReason"`
+
+	Synopsis string `json:"synopsis"`
+
+	SeasonCount    int    `json:"seasonCount"`
+	NumSeasonLabel string `json:"numSeasonLabel"`
+	EpisodeCount   int    `json:"episodeCount"`
+
+	DurationSec int64 `json:"durationSec"`
+
+	AvailabilityStartTime string `json:"availabilityStartTime"`
+
+	DataFrom  string `json:"dataFrom"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// The condition of the videos in my playlist now.
+const inListCondition = `COALESCE((SELECT action FROM playlist_status_events AS events WHERE events.video_id = playlist.video_id
+	AND events.action != 'unknown' ORDER BY events.occurred_at DESC, events.rowid DESC LIMIT 1), '') = 'add'`
+
+// The columns scanned by scanPlaylistVideo.
+const playlistColumns = `rowid, video_id, type, title, status, casting, creators, directors, writers, genres, mood, tags,
+	age_advised, age_advised_reason, synopsis, season_count, num_season_label, episode_count, duration_sec,
+	availability_starttime, _data_from, created_at, updated_at, ` + inListCondition + `,
+	COALESCE((SELECT MIN(occurred_at) FROM playlist_status_events AS events WHERE events.video_id = playlist.video_id AND events.action = 'add'), '')`
+
+func scanPlaylistVideo(row interface{ Scan(dest ...any) error }) (*PlaylistVideo, error) {
+	var video = &PlaylistVideo{}
+	var scanErr = row.Scan(&video.Rowid, &video.VideoId, &video.Type, &video.Title, &video.Status, &video.Casting, &video.Creators, &video.Directors, &video.Writers,
+		&video.Genres, &video.Mood, &video.Tags, &video.AgeAdvised, &video.AgeAdvisedReason, &video.Synopsis, &video.SeasonCount, &video.NumSeasonLabel,
+		&video.EpisodeCount, &video.DurationSec, &video.AvailabilityStartTime, &video.DataFrom, &video.CreatedAt, &video.UpdatedAt, &video.InList, &video.FirstAddedAt)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return video, nil
+}
+
+// Get the videos of the playlist matching the given query, and the cursor of the next page (empty if it's the last one).
+func (store *Store) GetPlaylist(query PlaylistQuery) ([]PlaylistVideo, string, error) {
+	var sqlQuery, args, queryErr = query.toSQL()
+	if queryErr != nil {
+		return nil, "", queryErr
+	}
+
+	var rows, rowsErr = store.connection.Query(sqlQuery, args...)
+	if rowsErr != nil {
+		return nil, "", rowsErr
+	}
+	defer rows.Close()
+
+	var videos = []PlaylistVideo{}
+	for rows.Next() {
+		var video, scanErr = scanPlaylistVideo(rows)
+		if scanErr != nil {
+			return nil, "", scanErr
+		}
+		videos = append(videos, *video)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, "", rowsErr
+	}
+
+	var nextCursor = ""
+	if query.Limit > 0 && len(videos) > query.Limit {
+		videos = videos[:query.Limit]
+		var cursorErr error
+		nextCursor, cursorErr = query.nextCursor(&videos[query.Limit-1])
+		if cursorErr != nil {
+			return nil, "", cursorErr
+		}
+	}
+	return videos, nextCursor, nil
+}
+
+// Get a video of the playlist, with all the statuses received.
+//
+// Return sql.ErrNoRows if the video isn't in the playlist.
+func (store *Store) GetPlaylistVideo(videoId int64) (*PlaylistVideo, error) {
+	var row = store.connection.QueryRow("SELECT "+playlistColumns+" FROM playlist WHERE video_id = ?;", videoId)
+	var video, scanErr = scanPlaylistVideo(row)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	var events, eventsErr = store.getStatusEvents(videoId)
+	if eventsErr != nil {
+		return nil, eventsErr
+	}
+	video.StatusEvents = events
+	return video, nil
+}
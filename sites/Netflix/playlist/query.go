@@ -0,0 +1,153 @@
+package netflix
+
+import (
+	"fmt"
+	pagination "mylocalhost/utils/pagination"
+	"strings"
+)
+
+// The filters, the sorting and the pagination of the videos of the playlist to get.
+type PlaylistQuery struct {
+	// movie, show... Empty for every type.
+	Type string
+	// Only the movies, whatever the case of their type. It's used by the exports to Letterboxd.
+	moviesOnly bool
+	// A part of the genres, the moods, the tags or the cast, case insensitive.
+	Genre string
+	Mood  string
+	Tag   string
+	Cast  string
+	// The exact age advised, or the maximum one. nil for every age.
+	AgeAdvised    *int
+	MaxAgeAdvised *int
+	// The current status of the videos (the last one received).
+	Status string
+	// Only the videos in my playlist now (true), or removed from it (false). nil for all of them.
+	InList *bool
+
+	// createdAt or updatedAt. Empty to sort in the insertion order.
+	Sort       string
+	Descending bool
+
+	// The maximum number of videos to get. 0 for all of them.
+	Limit int
+	// The cursor returned by the previous page.
+	Cursor string
+}
+
+// The columns the videos can be sorted by, by the name used in the requests.
+var sortColumns = map[string]string{
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+func (query *PlaylistQuery) validate() error {
+	if _, keyExists := sortColumns[query.Sort]; query.Sort != "" && keyExists == false {
+		return fmt.Errorf("The sort \"%s\" is invalid (should be either createdAt/updatedAt)", query.Sort)
+	}
+	if query.Limit < 0 {
+		return fmt.Errorf("The limit can't be negative")
+	}
+	if query.Cursor != "" {
+		if _, cursorErr := pagination.DecodeCursorForSort(query.Cursor, query.Sort, query.Descending); cursorErr != nil {
+			return cursorErr
+		}
+	}
+	return nil
+}
+
+// Build the SQL query of the videos, and its arguments.
+func (query *PlaylistQuery) toSQL() (string, []any, error) {
+	if validateErr := query.validate(); validateErr != nil {
+		return "", nil, validateErr
+	}
+
+	var sortColumn = "rowid"
+	if query.Sort != "" {
+		sortColumn = sortColumns[query.Sort]
+	}
+
+	var conditions []string
+	var args []any
+	if query.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, query.Type)
+	}
+	if query.moviesOnly {
+		conditions = append(conditions, "lower(type) IN ("+placeholders(len(movieTypes))+")")
+		for _, movieType := range movieTypes {
+			args = append(args, movieType)
+		}
+	}
+	var partFilters = [][2]string{{"genres", query.Genre}, {"mood", query.Mood}, {"tags", query.Tag}, {"casting", query.Cast}}
+	for _, partFilter := range partFilters {
+		if partFilter[1] != "" {
+			conditions = append(conditions, "instr(lower("+partFilter[0]+"), lower(?)) > 0")
+			args = append(args, partFilter[1])
+		}
+	}
+	if query.AgeAdvised != nil {
+		conditions = append(conditions, "age_advised = ?")
+		args = append(args, *query.AgeAdvised)
+	}
+	if query.MaxAgeAdvised != nil {
+		conditions = append(conditions, "age_advised <= ?")
+		args = append(args, *query.MaxAgeAdvised)
+	}
+	if query.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, query.Status)
+	}
+	if query.InList != nil {
+		if *query.InList {
+			conditions = append(conditions, inListCondition)
+		} else {
+			conditions = append(conditions, "NOT "+inListCondition)
+		}
+	}
+
+	if query.Cursor != "" {
+		var cursor, cursorErr = pagination.DecodeCursor(query.Cursor)
+		if cursorErr != nil {
+			return "", nil, cursorErr
+		}
+		var condition, conditionArgs = cursor.Condition(sortColumn, "rowid")
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	var sqlQuery = "SELECT " + playlistColumns + " FROM playlist"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var order = "ASC"
+	if query.Descending {
+		order = "DESC"
+	}
+	if query.Sort == "" {
+		sqlQuery += " ORDER BY rowid " + order
+	} else {
+		sqlQuery += fmt.Sprintf(" ORDER BY %s %s, rowid %s", sortColumn, order, order)
+	}
+
+	if query.Limit > 0 {
+		//.. I get one more video to know if there is a next page.
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+	return sqlQuery + ";", args, nil
+}
+
+// Make the cursor of the page following the given video.
+func (query *PlaylistQuery) nextCursor(lastVideo *PlaylistVideo) (string, error) {
+	var cursor = pagination.Cursor{Sort: query.Sort, Descending: query.Descending, Rowid: lastVideo.Rowid}
+	switch query.Sort {
+	case "createdAt":
+		cursor.Value = lastVideo.CreatedAt
+	case "updatedAt":
+		cursor.Value = lastVideo.UpdatedAt
+	}
+
+	return cursor.Encode()
+}
@@ -0,0 +1,136 @@
+package netflix
+
+import (
+	"fmt"
+	dates "mylocalhost/utils/dates"
+	"sort"
+)
+
+// The state of a video to restore: the one right after an entry of its history, or the one at a date.
+type RevertTarget struct {
+	// The id of the entry of the history (see PlaylistChange).
+	UpdateId int64 `json:"updateId"`
+	// A date, which is compared on its length, so "2024-05-17" is the state before any change of this day.
+	At string `json:"at"`
+}
+
+func (target *RevertTarget) validate() error {
+	if target.UpdateId == 0 && target.At == "" {
+		return fmt.Errorf("No updateId or at given")
+	}
+	if target.UpdateId != 0 && target.At != "" {
+		return fmt.Errorf("The updateId and the at can't be both given")
+	}
+	if target.UpdateId < 0 {
+		return fmt.Errorf("The updateId can't be negative")
+	}
+	if target.At != "" {
+		return validateDate("at", target.At)
+	}
+	return nil
+}
+
+// The changes made to revert a video.
+type RevertResult struct {
+	VideoId int64 `json:"videoId"`
+	// The date of the state restored.
+	RevertedTo string `json:"revertedTo"`
+	// The kind of SQL request made (UPDATE/NONE)
+	Query string `json:"query"`
+
+	UpdatedColumns []string `json:"updatedColumns"`
+	OldValues      []any    `json:"oldValues"`
+	NewValues      []any    `json:"newValues"`
+
+	// If the changes were rolled back instead of committed, to preview them.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Restore the columns of the given video to their state at the target, by undoing the changes made after it.
+// The revert is saved in the history like any other change, so it can be reverted too.
+//
+// Return sql.ErrNoRows if the video isn't in the playlist, or if the entry of the history isn't one of the video.
+// With dryRun, the transaction is rolled back instead of committed.
+func (store *Store) RevertVideo(videoId int64, target RevertTarget, dryRun bool) (*RevertResult, error) {
+	if validateErr := target.validate(); validateErr != nil {
+		return nil, validateErr
+	}
+
+	var transaction, transactionErr = store.connection.Begin()
+	if transactionErr != nil {
+		return nil, transactionErr
+	}
+	defer transaction.Rollback()
+
+	var video, videoErr = getVideo(transaction, videoId)
+	if videoErr != nil {
+		return nil, videoErr
+	}
+
+	//.. The changes to undo are the ones after the target.
+	var result = &RevertResult{VideoId: videoId, RevertedTo: target.At, Query: "NONE", DryRun: dryRun}
+	var condition = "playlist_updates.updated_at > ?"
+	var args = []any{videoId, target.At}
+	if target.UpdateId != 0 {
+		var scanErr = transaction.QueryRow("SELECT updated_at FROM playlist_updates WHERE rowid = ? AND video_id = ?;", target.UpdateId, videoId).
+			Scan(&result.RevertedTo)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		condition = "(playlist_updates.updated_at > ? OR (playlist_updates.updated_at = ? AND playlist_updates.rowid > ?))"
+		args = []any{videoId, result.RevertedTo, result.RevertedTo, target.UpdateId}
+	}
+
+	var currentValues = make(map[string]any)
+	for i, value := range videoFieldValues(video) {
+		currentValues[videoFields[i].column] = value
+	}
+
+	var replayedValues, replayErr = replayChangesBackwards(transaction, "playlist_updates.video_id = ? AND "+condition, args...)
+	if replayErr != nil {
+		return nil, replayErr
+	}
+	var revertedValues = replayedValues[videoId]
+
+	var columns []string
+	for column, revertedValue := range revertedValues {
+		if fmt.Sprint(revertedValue) != fmt.Sprint(currentValues[column]) {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return result, nil
+	}
+	sort.Strings(columns)
+
+	var linkValues = make(map[string]string)
+	for _, column := range columns {
+		result.UpdatedColumns = append(result.UpdatedColumns, column)
+		result.OldValues = append(result.OldValues, currentValues[column])
+		result.NewValues = append(result.NewValues, revertedValues[column])
+		if value, isString := revertedValues[column].(string); isString {
+			linkValues[column] = value
+		}
+	}
+	result.Query = "UPDATE"
+
+	video.UpdatedAt = dates.NowToString()
+	if updateErr := update(transaction, video, result.UpdatedColumns, result.NewValues); updateErr != nil {
+		return nil, updateErr
+	}
+	if syncErr := syncVideoLinks(transaction, videoId, linkValues); syncErr != nil {
+		return nil, syncErr
+	}
+	if insertUpdatesErr := insertPlaylistUpdates(transaction, video, result.UpdatedColumns, result.NewValues, result.OldValues, result.RevertedTo); insertUpdatesErr != nil {
+		return nil, insertUpdatesErr
+	}
+
+	if dryRun {
+		//.. The deferred rollback cancels the changes.
+		return result, nil
+	}
+	if commitErr := transaction.Commit(); commitErr != nil {
+		return nil, commitErr
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package netflix
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// What a status received from my Chrome extension means for my playlist.
+const (
+	statusActionAdd    = "add"
+	statusActionRemove = "remove"
+	// A status I don't know, kept in the history without changing whether the video is in my playlist.
+	statusActionUnknown = "unknown"
+)
+
+// A status received for a video of my playlist, when I added or removed it.
+type StatusEvent struct {
+	// The text received.
+	Status     string `json:"status"`
+	Action     string `json:"action"`
+	OccurredAt string `json:"occurredAt"`
+}
+
+// The actions of the statuses are learned from the statuses stored, and kept in the table playlist_statuses:
+//   - The first status received for a video is an addition, since my Chrome extension only sends a video once I've added it
+//     to my playlist. Its text is therefore saved as a status of addition.
+//   - The next statuses get the action learned for their text. A status I remove videos with is never the first one,
+//     so it stays unknown until I give its action with the command netflix-statuses (see SetStatusAction).
+
+// Get the action of the given status, and learn it if it's the first status of the video.
+func statusAction(transaction *sql.Tx, status string, isFirst bool) (string, error) {
+	if isFirst {
+		//.. A status I've classified myself isn't changed.
+		var _, execErr = transaction.Exec(`INSERT INTO playlist_statuses(status, action) VALUES(?, 'add')
+			ON CONFLICT(status) DO UPDATE SET action = 'add' WHERE action = 'unknown';`, status)
+		return statusActionAdd, execErr
+	}
+
+	var action string
+	var scanErr = transaction.QueryRow("SELECT action FROM playlist_statuses WHERE status = ?;", status).Scan(&action)
+	if scanErr == sql.ErrNoRows {
+		//.. The unknown status is saved, so I can find it and give its action.
+		var _, execErr = transaction.Exec("INSERT INTO playlist_statuses(status, action) VALUES(?, 'unknown');", status)
+		return statusActionUnknown, execErr
+	}
+	return action, scanErr
+}
+
+// Save a status received for the video. `isFirst` is true for the first status of the video.
+func insertStatusEvent(transaction *sql.Tx, videoId int64, status string, occurredAt string, isFirst bool) error {
+	var action, actionErr = statusAction(transaction, status, isFirst)
+	if actionErr != nil {
+		return actionErr
+	}
+	var _, execErr = transaction.Exec("INSERT INTO playlist_status_events(video_id, status, action, occurred_at) VALUES(?, ?, ?, ?);",
+		videoId, status, action, occurredAt)
+	return execErr
+}
+
+// A status received, with its action and the number of times it was received.
+type StatusSummary struct {
+	Status     string `json:"status"`
+	Action     string `json:"action"`
+	EventCount int    `json:"eventCount"`
+}
+
+// Get the statuses received, with their action.
+func (store *Store) GetStatuses() ([]StatusSummary, error) {
+	var rows, queryErr = store.connection.Query(`SELECT statuses.status, statuses.action, COUNT(events.rowid) FROM playlist_statuses AS statuses
+		LEFT JOIN playlist_status_events AS events ON events.status = statuses.status
+		GROUP BY statuses.status ORDER BY statuses.status;`)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var statuses = []StatusSummary{}
+	for rows.Next() {
+		var summary = StatusSummary{}
+		if scanErr := rows.Scan(&summary.Status, &summary.Action, &summary.EventCount); scanErr != nil {
+			return nil, scanErr
+		}
+		statuses = append(statuses, summary)
+	}
+	return statuses, rows.Err()
+}
+
+// Give the action of a status, and set it to the statuses already received, except the first status of each video.
+//
+// Return the number of statuses changed, or sql.ErrNoRows if the status was never received.
+func (store *Store) SetStatusAction(status string, action string) (int64, error) {
+	if action != statusActionAdd && action != statusActionRemove && action != statusActionUnknown {
+		return 0, fmt.Errorf("The action \"%s\" is invalid (should be either add/remove/unknown)", action)
+	}
+
+	var transaction, transactionErr = store.connection.Begin()
+	if transactionErr != nil {
+		return 0, transactionErr
+	}
+	defer transaction.Rollback()
+
+	var result, execErr = transaction.Exec("UPDATE playlist_statuses SET action = ? WHERE status = ?;", action, status)
+	if execErr != nil {
+		return 0, execErr
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	result, execErr = transaction.Exec(`UPDATE playlist_status_events SET action = ? WHERE status = ? AND action != ?
+		AND EXISTS (SELECT 1 FROM playlist_status_events AS previous WHERE previous.video_id = playlist_status_events.video_id
+			AND (previous.occurred_at < playlist_status_events.occurred_at
+				OR (previous.occurred_at = playlist_status_events.occurred_at AND previous.rowid < playlist_status_events.rowid)));`,
+		action, status, action)
+	if execErr != nil {
+		return 0, execErr
+	}
+	var changedCount, _ = result.RowsAffected()
+	return changedCount, transaction.Commit()
+}
+
+// Get the statuses received for the given video, from the oldest to the newest.
+func (store *Store) getStatusEvents(videoId int64) ([]StatusEvent, error) {
+	var rows, queryErr = store.connection.Query("SELECT status, action, occurred_at FROM playlist_status_events WHERE video_id = ? ORDER BY occurred_at, rowid;", videoId)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var events = []StatusEvent{}
+	for rows.Next() {
+		var event = StatusEvent{}
+		if scanErr := rows.Scan(&event.Status, &event.Action, &event.OccurredAt); scanErr != nil {
+			return nil, scanErr
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,361 @@
+package netflix
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A connection or a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Undo the changes of the table playlist_updates matching the condition, from the newest to the oldest.
+//
+// Return, by video id, the columns changed with the value they had before the oldest of these changes.
+func replayChangesBackwards(connection querier, condition string, args ...any) (map[int64]map[string]any, error) {
+	var rows, queryErr = connection.Query(`SELECT playlist_updates.video_id, json_extract(change.value, '$.column'), json_extract(change.value, '$.oldValue')
+		FROM playlist_updates
+		INNER JOIN json_each(playlist_updates.updates) AS change
+		WHERE `+condition+`
+		ORDER BY playlist_updates.updated_at DESC, playlist_updates.rowid DESC, change.key DESC;`, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var values = make(map[int64]map[string]any)
+	for rows.Next() {
+		var videoId int64
+		var column sql.NullString
+		var oldValue any
+		if scanErr := rows.Scan(&videoId, &column, &oldValue); scanErr != nil {
+			return nil, scanErr
+		}
+		if isVideoColumn(column.String) == false {
+			continue
+		}
+		if values[videoId] == nil {
+			values[videoId] = make(map[string]any)
+		}
+		values[videoId][column.String] = oldValue
+	}
+	return values, rows.Err()
+}
+
+func isVideoColumn(column string) bool {
+	for _, field := range videoFields {
+		if field.column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Set the value of a column of the video, read from the history.
+func setVideoColumn(video *videoData, column string, value any) {
+	for _, field := range videoFields {
+		if field.column != column {
+			continue
+		}
+		var fieldValue = reflect.ValueOf(video).Elem().Field(field.index)
+		switch fieldValue.Kind() {
+		case reflect.String:
+			if value == nil {
+				fieldValue.SetString("")
+			} else {
+				fieldValue.SetString(fmt.Sprint(value))
+			}
+		case reflect.Int, reflect.Int64:
+			switch number := value.(type) {
+			case int64:
+				fieldValue.SetInt(number)
+			case float64:
+				fieldValue.SetInt(int64(number))
+			}
+		}
+		return
+	}
+}
+
+// A video of the playlist as it was at a date.
+type playlistSnapshotVideo struct {
+	video  *videoData
+	status string
+	inList bool
+	// When the video was added for the first time, before the date.
+	firstAddedAt string
+	// The last change of the video before the date.
+	updatedAt string
+	events    []StatusEvent
+}
+
+func (snapshotVideo *playlistSnapshotVideo) toPlaylistVideo() PlaylistVideo {
+	var video = snapshotVideo.video
+	return PlaylistVideo{
+		Rowid: video.Rowid, VideoId: video.VideoId, Type: video.Type, Title: video.Title,
+		Status: snapshotVideo.status, InList: snapshotVideo.inList, FirstAddedAt: snapshotVideo.firstAddedAt,
+		Casting: video.Casting, Creators: video.Creators, Directors: video.Directors, Writers: video.Writers,
+		Genres: video.Genres, Mood: video.Mood, Tags: video.Tags,
+		AgeAdvised: video.AgeAdvised, AgeAdvisedReason: video.AgeAdvisedReason, Synopsis: video.Synopsis,
+		SeasonCount: video.SeasonCount, NumSeasonLabel: video.NumSeasonLabel, EpisodeCount: video.EpisodeCount,
+		DurationSec: video.DurationSec, AvailabilityStartTime: video.AvailabilityStartTime, DataFrom: video.DataFrom_,
+		CreatedAt: video.CreatedAt, UpdatedAt: snapshotVideo.updatedAt,
+	}
+}
+
+// The lengths of the starts of a date saved in database which can be given instead of a full date: 2006, 2006-01, 2006-01-02, 2006-01-02 15...
+var datePrefixLengths = []int{4, 7, 10, 13, 16, 19, 21, 22, 23}
+
+// Check that the date is formatted like the dates saved in database (2006-01-02 15:04:05.000), or is the start of one like 2006-01-02.
+func validateDate(name string, date string) error {
+	if date == "" {
+		return fmt.Errorf("No %s given", name)
+	}
+	const layout = "2006-01-02 15:04:05.000"
+	for _, length := range datePrefixLengths {
+		if len(date) == length {
+			if _, parseErr := time.Parse(layout[:length], date); parseErr == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("The %s \"%s\" is not a date like 2006-01-02 or 2006-01-02 15:04:05.000", name, date)
+}
+
+// Rebuild the videos of the playlist as they were at the given date, by undoing the changes made after it.
+// The date is compared on its length, so "2025-01-01" is the state before any change of this day.
+//
+// videoId restricts the snapshot to a single video, 0 for the whole playlist. The videos are in the order they were added.
+func (store *Store) getPlaylistSnapshot(at string, videoId int64) ([]*playlistSnapshotVideo, error) {
+	if dateErr := validateDate("date", at); dateErr != nil {
+		return nil, dateErr
+	}
+
+	var videoCondition, videoArgs = videoFilter("video_id", videoId)
+	var rows, queryErr = store.connection.Query("SELECT rowid, "+strings.Join(videoColumns(), ", ")+", created_at FROM playlist WHERE created_at <= ?"+
+		videoCondition+" ORDER BY rowid;", append([]any{at}, videoArgs...)...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	var snapshot []*playlistSnapshotVideo
+	var snapshotByVideoId = make(map[int64]*playlistSnapshotVideo)
+	for rows.Next() {
+		var video = &videoData{}
+		if scanErr := rows.Scan(append(append([]any{&video.Rowid}, videoFieldPointers(video)...), &video.CreatedAt)...); scanErr != nil {
+			rows.Close()
+			return nil, scanErr
+		}
+		var snapshotVideo = &playlistSnapshotVideo{video: video, events: []StatusEvent{}}
+		snapshot = append(snapshot, snapshotVideo)
+		snapshotByVideoId[video.VideoId] = snapshotVideo
+	}
+	rows.Close()
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	var updatesCondition, updatesArgs = videoFilter("playlist_updates.video_id", videoId)
+	var replayedValues, replayErr = replayChangesBackwards(store.connection, "playlist_updates.updated_at > ?"+updatesCondition, append([]any{at}, updatesArgs...)...)
+	if replayErr != nil {
+		return nil, replayErr
+	}
+	for replayedVideoId, values := range replayedValues {
+		if snapshotVideo, keyExists := snapshotByVideoId[replayedVideoId]; keyExists {
+			for column, value := range values {
+				setVideoColumn(snapshotVideo.video, column, value)
+			}
+		}
+	}
+
+	if updatesErr := store.addSnapshotUpdatedAt(snapshotByVideoId, at, videoCondition, videoArgs...); updatesErr != nil {
+		return nil, updatesErr
+	}
+	if eventsErr := store.addSnapshotStatusEvents(snapshotByVideoId, at, videoCondition, videoArgs...); eventsErr != nil {
+		return nil, eventsErr
+	}
+	return snapshot, nil
+}
+
+// Make the condition on the given column of the video id, and its arguments. No condition for the video id 0.
+func videoFilter(column string, videoId int64) (string, []any) {
+	if videoId == 0 {
+		return "", nil
+	}
+	return " AND " + column + " = ?", []any{videoId}
+}
+
+// Add to the videos the date of their last change before the given date.
+func (store *Store) addSnapshotUpdatedAt(snapshotByVideoId map[int64]*playlistSnapshotVideo, at string, videoCondition string, videoArgs ...any) error {
+	var rows, queryErr = store.connection.Query("SELECT video_id, MAX(updated_at) FROM playlist_updates WHERE updated_at <= ?"+videoCondition+" GROUP BY video_id;",
+		append([]any{at}, videoArgs...)...)
+	if queryErr != nil {
+		return queryErr
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var videoId int64
+		var updatedAt string
+		if scanErr := rows.Scan(&videoId, &updatedAt); scanErr != nil {
+			return scanErr
+		}
+		if snapshotVideo, keyExists := snapshotByVideoId[videoId]; keyExists {
+			snapshotVideo.updatedAt = updatedAt
+		}
+	}
+	return rows.Err()
+}
+
+// Add to the videos the statuses received before the given date, and the status they had then.
+func (store *Store) addSnapshotStatusEvents(snapshotByVideoId map[int64]*playlistSnapshotVideo, at string, videoCondition string, videoArgs ...any) error {
+	var rows, queryErr = store.connection.Query("SELECT video_id, status, action, occurred_at FROM playlist_status_events WHERE occurred_at <= ?"+videoCondition+
+		" ORDER BY occurred_at, rowid;", append([]any{at}, videoArgs...)...)
+	if queryErr != nil {
+		return queryErr
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var videoId int64
+		var event = StatusEvent{}
+		if scanErr := rows.Scan(&videoId, &event.Status, &event.Action, &event.OccurredAt); scanErr != nil {
+			return scanErr
+		}
+		var snapshotVideo, keyExists = snapshotByVideoId[videoId]
+		if keyExists == false {
+			continue
+		}
+		snapshotVideo.events = append(snapshotVideo.events, event)
+		snapshotVideo.status = event.Status
+		if event.Action != statusActionUnknown {
+			snapshotVideo.inList = event.Action == statusActionAdd
+		}
+		if event.Action == statusActionAdd && snapshotVideo.firstAddedAt == "" {
+			snapshotVideo.firstAddedAt = event.OccurredAt
+		}
+	}
+	return rows.Err()
+}
+
+// Get the videos of the playlist as they were at the given date, in the order they were added.
+//
+// inList keeps only the videos which were in my playlist then (true), or removed from it (false). nil for all of them.
+func (store *Store) GetPlaylistAt(at string, inList *bool) ([]PlaylistVideo, error) {
+	var snapshot, snapshotErr = store.getPlaylistSnapshot(at, 0)
+	if snapshotErr != nil {
+		return nil, snapshotErr
+	}
+	var videos = []PlaylistVideo{}
+	for _, snapshotVideo := range snapshot {
+		if inList == nil || *inList == snapshotVideo.inList {
+			videos = append(videos, snapshotVideo.toPlaylistVideo())
+		}
+	}
+	return videos, nil
+}
+
+// Get a video of the playlist as it was at the given date, with the statuses received until then.
+//
+// Return sql.ErrNoRows if the video wasn't in the playlist yet.
+func (store *Store) GetPlaylistVideoAt(videoId int64, at string) (*PlaylistVideo, error) {
+	var snapshot, snapshotErr = store.getPlaylistSnapshot(at, videoId)
+	if snapshotErr != nil {
+		return nil, snapshotErr
+	}
+	if len(snapshot) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	var video = snapshot[0].toPlaylistVideo()
+	video.StatusEvents = snapshot[0].events
+	return &video, nil
+}
+
+// The differences of my playlist between two dates.
+type PlaylistDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// The videos in my playlist at the second date but not at the first one.
+	Added []PlaylistDiffVideo `json:"added"`
+	// The videos in my playlist at the first date but not at the second one.
+	Removed []PlaylistDiffVideo `json:"removed"`
+	// The videos whose data have changed between the two dates.
+	Changed []PlaylistDiffVideo `json:"changed"`
+}
+
+// A video of the diff of my playlist.
+type PlaylistDiffVideo struct {
+	VideoId int64  `json:"videoId"`
+	Title   string `json:"title"`
+	// The changes of the columns. Only sent for the videos changed.
+	Changes []PlaylistDiffChange `json:"changes,omitempty"`
+}
+
+// A column of a video whose value is different at the two dates.
+type PlaylistDiffChange struct {
+	// The field of PlaylistVideo matching the column.
+	Column   string `json:"column"`
+	OldValue any    `json:"oldValue"`
+	NewValue any    `json:"newValue"`
+}
+
+// Compare my playlist at the two given dates.
+func (store *Store) GetPlaylistDiff(from string, to string) (*PlaylistDiff, error) {
+	if dateErr := validateDate("from", from); dateErr != nil {
+		return nil, dateErr
+	}
+	if dateErr := validateDate("to", to); dateErr != nil {
+		return nil, dateErr
+	}
+	if from > to {
+		return nil, fmt.Errorf("The from must be before the to")
+	}
+
+	var fromSnapshot, fromErr = store.getPlaylistSnapshot(from, 0)
+	if fromErr != nil {
+		return nil, fromErr
+	}
+	var toSnapshot, toErr = store.getPlaylistSnapshot(to, 0)
+	if toErr != nil {
+		return nil, toErr
+	}
+	var fromByVideoId = make(map[int64]*playlistSnapshotVideo)
+	for _, snapshotVideo := range fromSnapshot {
+		fromByVideoId[snapshotVideo.video.VideoId] = snapshotVideo
+	}
+
+	var diff = &PlaylistDiff{From: from, To: to, Added: []PlaylistDiffVideo{}, Removed: []PlaylistDiffVideo{}, Changed: []PlaylistDiffVideo{}}
+	for _, toVideo := range toSnapshot {
+		var diffVideo = PlaylistDiffVideo{VideoId: toVideo.video.VideoId, Title: toVideo.video.Title}
+		var fromVideo, existed = fromByVideoId[toVideo.video.VideoId]
+		var wasInList = existed && fromVideo.inList
+		if toVideo.inList && wasInList == false {
+			diff.Added = append(diff.Added, diffVideo)
+		} else if toVideo.inList == false && wasInList {
+			diff.Removed = append(diff.Removed, diffVideo)
+		}
+		if existed == false {
+			continue
+		}
+
+		var fromValues = videoFieldValues(fromVideo.video)
+		var toValues = videoFieldValues(toVideo.video)
+		for i, field := range videoFields {
+			if field.merge == mergeNever || fmt.Sprint(fromValues[i]) == fmt.Sprint(toValues[i]) {
+				continue
+			}
+			diffVideo.Changes = append(diffVideo.Changes, PlaylistDiffChange{Column: fieldOfColumn(field.column), OldValue: fromValues[i], NewValue: toValues[i]})
+		}
+		if len(diffVideo.Changes) > 0 {
+			sort.Slice(diffVideo.Changes, func(i, j int) bool {
+				return diffVideo.Changes[i].Column < diffVideo.Changes[j].Column
+			})
+			diff.Changed = append(diff.Changed, diffVideo)
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,68 @@
+package youtube
+
+import (
+	"fmt"
+	validation "mylocalhost/utils/validation"
+	"sort"
+)
+
+// The result of the rating of a video sent in a batch.
+type BatchRatingResult struct {
+	// The index of the video in the batch.
+	Index   int    `json:"index"`
+	VideoId string `json:"videoId"`
+	// inserted/updated/unchanged/outdated (a newer rating is already saved) or error.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// The problems with the fields of the rating, when it's invalid.
+	FieldErrors validation.FieldErrors `json:"fieldErrors,omitempty"`
+}
+
+// Rate the given videos in a single transaction, from the oldest rating to the newest,
+// and return the result of each of them in the order they are given.
+//
+// Each video is rated in a savepoint, so an error with one of them doesn't cancel the others.
+func (store *Store) SetVideoRatings(videos []*videoToRate) ([]BatchRatingResult, error) {
+	var results = make([]BatchRatingResult, len(videos))
+	var order = make([]int, len(videos))
+	for i, video := range videos {
+		results[i] = BatchRatingResult{Index: i, VideoId: video.VideoId}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a int, b int) bool {
+		return videos[order[a]].RatedAt < videos[order[b]].RatedAt
+	})
+
+	var transaction, transactionErr = store.begin()
+	if transactionErr != nil {
+		return nil, transactionErr
+	}
+	defer transaction.Rollback()
+
+	for _, i := range order {
+		if savepointErr := transaction.savepoint("rating"); savepointErr != nil {
+			return nil, savepointErr
+		}
+		var outcome, rateErr = store.rateVideo(transaction, videos[i])
+		if rateErr != nil {
+			results[i].Result = "error"
+			results[i].Error = rateErr.Error()
+			if fieldErrors, isFieldErrors := rateErr.(validation.FieldErrors); isFieldErrors {
+				results[i].FieldErrors = fieldErrors
+			}
+			if rollbackErr := transaction.rollbackTo("rating"); rollbackErr != nil {
+				return nil, fmt.Errorf("Rolling back the rating of the video %s: %w", videos[i].VideoId, rollbackErr)
+			}
+		} else {
+			results[i].Result = outcome
+		}
+		if releaseErr := transaction.release("rating"); releaseErr != nil {
+			return nil, releaseErr
+		}
+	}
+
+	if commitErr := transaction.commit(); commitErr != nil {
+		return nil, commitErr
+	}
+	return results, nil
+}
@@ -0,0 +1,254 @@
+package youtube
+
+import (
+	"database/sql"
+	"fmt"
+	"mylocalhost/config"
+	cache "mylocalhost/utils/cache"
+)
+
+// The modes of the cache of the ratings, chosen by the config "Youtube.ratedVideos.cacheVideoRankings".
+const (
+	// The ratings are always read from database ("false" too).
+	cacheModeOff = "off"
+	// The ratings read or saved are kept in cache ("true" too).
+	cacheModeLRU = "lru"
+	// Like lru, and the cache is filled with the last rated videos when the database is opened.
+	cacheModeWarm = "warm"
+)
+
+const defaultCacheSize = 1000
+
+// The options of the caches of a store.
+type CacheOptions struct {
+	// off, lru or warm.
+	Mode string
+	// The maximum number of videos, and of channels, kept in cache.
+	Size int
+}
+
+// Read the cache options from the config "Youtube.ratedVideos.cacheVideoRankings" and "Youtube.ratedVideos.cacheSize".
+func ReadCacheOptions() (CacheOptions, error) {
+	var options = CacheOptions{Size: config.GetInt("Youtube.ratedVideos.cacheSize", defaultCacheSize)}
+	switch mode := config.Get("Youtube.ratedVideos.cacheVideoRankings"); mode {
+	case "", "false", cacheModeOff:
+		options.Mode = cacheModeOff
+	case "true", cacheModeLRU:
+		options.Mode = cacheModeLRU
+	case cacheModeWarm:
+		options.Mode = cacheModeWarm
+	default:
+		return options, fmt.Errorf("The config Youtube.ratedVideos.cacheVideoRankings is invalid (should be either off/lru/warm): %s", mode)
+	}
+	return options, nil
+}
+
+// Create the caches of the store, and fill them if the mode is warm.
+func (store *Store) initCaches(options CacheOptions) error {
+	switch options.Mode {
+	case cacheModeOff, cacheModeLRU, cacheModeWarm:
+	default:
+		return fmt.Errorf("The cache mode is invalid (should be either off/lru/warm): %s", options.Mode)
+	}
+
+	store.cacheMode = options.Mode
+	store.videosCache = cache.NewLRU[string, RatedVideo](options.Size)
+	store.channelsCache = cache.NewLRU[string, channel](options.Size)
+
+	if options.Mode == cacheModeWarm {
+		return store.warmCaches(options.Size)
+	}
+	return nil
+}
+
+// Fill the caches with the last rated videos and the last inserted channels.
+func (store *Store) warmCaches(size int) error {
+	//.. The oldest are added first, so the newest are the last to be evicted.
+	var rows, queryErr = store.connection.Query(`SELECT * FROM (
+			SELECT video_id, rowid, rating, created_at, updated_at, deleted_at, change_seq FROM videos ORDER BY change_seq DESC LIMIT ?
+		) ORDER BY change_seq ASC;`, size)
+	if queryErr != nil {
+		return queryErr
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var video RatedVideo
+		var changeSeq int64
+		if scanErr := rows.Scan(&video.VideoId, &video.Rowid, &video.Rating, &video.CreatedAt, &video.UpdatedAt, &video.DeletedAt, &changeSeq); scanErr != nil {
+			return scanErr
+		}
+		store.videosCache.Set(video.VideoId, video)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return rowsErr
+	}
+
+	var channelRows, channelsErr = store.connection.Query("SELECT * FROM (SELECT id, channel_id, name FROM channels ORDER BY id DESC LIMIT ?) ORDER BY id ASC;", size)
+	if channelsErr != nil {
+		return channelsErr
+	}
+	defer channelRows.Close()
+	for channelRows.Next() {
+		var savedChannel channel
+		if scanErr := channelRows.Scan(&savedChannel.Rowid, &savedChannel.ChannelId, &savedChannel.Name); scanErr != nil {
+			return scanErr
+		}
+		store.channelsCache.Set(savedChannel.ChannelId, savedChannel)
+	}
+	return channelRows.Err()
+}
+
+// A transaction of the store, with the changes of the caches which are only applied once it's committed.
+//
+// Only the rows written by the transaction are put in cache. The rows it reads are evicted instead,
+// since another transaction may have committed a newer value once this one is committed.
+// The changes of a savepoint are kept apart, so they are dropped if it's rolled back.
+type cachedTx struct {
+	*sql.Tx
+	store *Store
+	// The changes of the transaction, then the ones of each savepoint opened.
+	changes []*cacheChanges
+}
+
+// The videos and channels to put in cache, or to evict when they are nil.
+type cacheChanges struct {
+	videos   map[string]*RatedVideo
+	channels map[string]*channel
+}
+
+func newCacheChanges() *cacheChanges {
+	return &cacheChanges{videos: make(map[string]*RatedVideo), channels: make(map[string]*channel)}
+}
+
+func (store *Store) begin() (*cachedTx, error) {
+	var transaction, transactionErr = store.connection.Begin()
+	if transactionErr != nil {
+		return nil, transactionErr
+	}
+	return &cachedTx{Tx: transaction, store: store, changes: []*cacheChanges{newCacheChanges()}}, nil
+}
+
+// Commit the transaction, then update the caches.
+func (transaction *cachedTx) commit() error {
+	//.. The caches are updated in the order of the commits, so an older transaction can't put back the value it wrote.
+	transaction.store.commitMutex.Lock()
+	defer transaction.store.commitMutex.Unlock()
+
+	if commitErr := transaction.Tx.Commit(); commitErr != nil {
+		return commitErr
+	}
+	for videoId, video := range transaction.changes[0].videos {
+		if video == nil {
+			transaction.store.videosCache.Remove(videoId)
+		} else {
+			transaction.store.videosCache.Set(videoId, *video)
+		}
+	}
+	for channelId, savedChannel := range transaction.changes[0].channels {
+		if savedChannel == nil {
+			transaction.store.channelsCache.Remove(channelId)
+		} else {
+			transaction.store.channelsCache.Set(channelId, *savedChannel)
+		}
+	}
+	return nil
+}
+
+func (transaction *cachedTx) savepoint(name string) error {
+	if _, execErr := transaction.Exec("SAVEPOINT " + name + ";"); execErr != nil {
+		return execErr
+	}
+	transaction.changes = append(transaction.changes, newCacheChanges())
+	return nil
+}
+
+// Undo the changes made since the savepoint, which stays open.
+func (transaction *cachedTx) rollbackTo(name string) error {
+	if _, execErr := transaction.Exec("ROLLBACK TO " + name + ";"); execErr != nil {
+		return execErr
+	}
+	transaction.changes[len(transaction.changes)-1] = newCacheChanges()
+	return nil
+}
+
+// Close the savepoint, whose changes become the ones of the transaction or of the savepoint above.
+func (transaction *cachedTx) release(name string) error {
+	if _, execErr := transaction.Exec("RELEASE " + name + ";"); execErr != nil {
+		return execErr
+	}
+	var released = transaction.changes[len(transaction.changes)-1]
+	transaction.changes = transaction.changes[:len(transaction.changes)-1]
+	var changes = transaction.changes[len(transaction.changes)-1]
+	for videoId, video := range released.videos {
+		changes.videos[videoId] = video
+	}
+	for channelId, savedChannel := range released.channels {
+		changes.channels[channelId] = savedChannel
+	}
+	return nil
+}
+
+// Get the video written by the transaction, or else from the cache, if the cache of the ratings is used.
+func (transaction *cachedTx) getCachedVideo(videoId string) (RatedVideo, bool) {
+	if transaction.store.cacheMode == cacheModeOff {
+		return RatedVideo{}, false
+	}
+	for i := len(transaction.changes) - 1; i >= 0; i-- {
+		if video, keyExists := transaction.changes[i].videos[videoId]; keyExists {
+			if video == nil {
+				//.. The video is evicted, so it's read from database.
+				return RatedVideo{}, false
+			}
+			return *video, true
+		}
+	}
+	return transaction.store.videosCache.Get(videoId)
+}
+
+// Keep the video written by the transaction in cache once it's committed, if the cache of the ratings is used.
+func (transaction *cachedTx) cacheVideo(video *RatedVideo) {
+	if transaction.store.cacheMode != cacheModeOff {
+		var copied = *video
+		transaction.changes[len(transaction.changes)-1].videos[video.VideoId] = &copied
+	}
+}
+
+// Evict the video from the cache once the transaction is committed.
+func (transaction *cachedTx) evictVideo(videoId string) {
+	transaction.changes[len(transaction.changes)-1].videos[videoId] = nil
+}
+
+// Get the channel written by the transaction, or else from the cache.
+func (transaction *cachedTx) getCachedChannel(channelId string) (channel, bool) {
+	for i := len(transaction.changes) - 1; i >= 0; i-- {
+		if savedChannel, keyExists := transaction.changes[i].channels[channelId]; keyExists {
+			if savedChannel == nil {
+				return channel{}, false
+			}
+			return *savedChannel, true
+		}
+	}
+	return transaction.store.channelsCache.Get(channelId)
+}
+
+// Keep the channel written by the transaction in cache once it's committed.
+func (transaction *cachedTx) cacheChannel(savedChannel *channel) {
+	var copied = *savedChannel
+	transaction.changes[len(transaction.changes)-1].channels[savedChannel.ChannelId] = &copied
+}
+
+// Evict the channel from the cache once the transaction is committed.
+func (transaction *cachedTx) evictChannel(channelId string) {
+	transaction.changes[len(transaction.changes)-1].channels[channelId] = nil
+}
+
+// The state of the caches, to check that they are useful.
+type CacheDiagnostics struct {
+	Mode     string      `json:"mode"`
+	Videos   cache.Stats `json:"videos"`
+	Channels cache.Stats `json:"channels"`
+}
+
+func (store *Store) GetCacheDiagnostics() CacheDiagnostics {
+	return CacheDiagnostics{Mode: store.cacheMode, Videos: store.videosCache.Stats(), Channels: store.channelsCache.Stats()}
+}
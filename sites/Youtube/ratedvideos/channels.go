@@ -0,0 +1,230 @@
+package youtube
+
+import (
+	"database/sql"
+	"fmt"
+	dates "mylocalhost/utils/dates"
+	validation "mylocalhost/utils/validation"
+)
+
+// A Youtube channel, identified by its channel id. Its name can change.
+type channel struct {
+	Rowid     int64
+	ChannelId string
+	Name      string
+}
+
+// Get the rowid of the given channel, inserted if it's unknown.
+//
+// If the channel has a new name and `renamable` is true, it's renamed and its previous name is kept in the history of its names.
+// Return a validation.FieldErrors if the channel is unknown and its name is empty.
+func (store *Store) saveChannel(transaction *cachedTx, channelId string, name string, renamable bool) (int64, error) {
+	var savedChannel, getErr = store.getChannelByChannelId(transaction, channelId)
+	if getErr != nil {
+		if getErr == sql.ErrNoRows {
+			if name == "" {
+				return 0, validation.FieldErrors{{Field: "channelName", Message: fmt.Sprintf("The channelName is empty, but the channel %s is new", channelId)}}
+			}
+			return store.insertChannel(transaction, channelId, name)
+		}
+		return 0, getErr
+	}
+
+	if savedChannel.Name != name && renamable {
+		if renameErr := store.renameChannel(transaction, savedChannel, name); renameErr != nil {
+			return 0, renameErr
+		}
+	}
+	return savedChannel.Rowid, nil
+}
+
+func (store *Store) getChannelByChannelId(transaction *cachedTx, channelId string) (*channel, error) {
+	//.. The cache keeps a copy, so the channel can be changed without changing the cache.
+	if cachedChannel, keyExists := transaction.getCachedChannel(channelId); keyExists {
+		return &cachedChannel, nil
+	}
+
+	var stmt, stmtErr = transaction.Prepare("SELECT id, name FROM channels WHERE channel_id = ?")
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+	defer stmt.Close()
+
+	var savedChannel = &channel{ChannelId: channelId}
+	var scanErr = stmt.QueryRow(channelId).Scan(&savedChannel.Rowid, &savedChannel.Name)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	transaction.evictChannel(channelId)
+	return savedChannel, nil
+}
+
+func (store *Store) insertChannel(transaction *cachedTx, channelId string, name string) (int64, error) {
+	var stmt, stmtErr = transaction.Prepare("INSERT INTO channels(channel_id, name) VALUES(?, ?);")
+	if stmtErr != nil {
+		return 0, stmtErr
+	}
+	defer stmt.Close()
+
+	var result, execErr = stmt.Exec(channelId, name)
+	if execErr != nil {
+		return 0, execErr
+	}
+	var lastInsertId, _ = result.LastInsertId()
+	transaction.cacheChannel(&channel{Rowid: lastInsertId, ChannelId: channelId, Name: name})
+	return lastInsertId, nil
+}
+
+// Change the name of the channel, and keep its previous name in the channel_renames table.
+func (store *Store) renameChannel(transaction *cachedTx, savedChannel *channel, newName string) error {
+	var result, execErr = transaction.Exec("UPDATE channels SET name = ? WHERE id = ?;", newName, savedChannel.Rowid)
+	if execErr != nil {
+		return execErr
+	}
+	var rowsAffected, _ = result.RowsAffected()
+	if rowsAffected != 1 {
+		return fmt.Errorf("The rename of the channel %s to \"%s\" has affected %d rows", savedChannel.ChannelId, newName, rowsAffected)
+	}
+
+	_, execErr = transaction.Exec("INSERT INTO channel_renames(channel_id, old_name, new_name, renamed_at) VALUES(?, ?, ?, ?);",
+		savedChannel.Rowid, savedChannel.Name, newName, dates.NowToString())
+	if execErr != nil {
+		return execErr
+	}
+	savedChannel.Name = newName
+	transaction.cacheChannel(savedChannel)
+	return nil
+}
+
+// A channel and the statistics of the ratings of its videos.
+type ChannelStats struct {
+	ChannelId            string   `json:"channelId"`
+	Name                 string   `json:"name"`
+	LikeCount            int      `json:"likeCount"`
+	DislikeCount         int      `json:"dislikeCount"`
+	NoneCount            int      `json:"noneCount"`
+	TotalDurationSeconds int64    `json:"totalDurationSeconds"`
+	FirstRatedAt         string   `json:"firstRatedAt"`
+	LastRatedAt          string   `json:"lastRatedAt"`
+	PreviousNames        []string `json:"previousNames"`
+}
+
+// A channel, its statistics and its rated videos.
+type ChannelDetail struct {
+	ChannelStats
+	Videos []RatedVideo `json:"videos"`
+}
+
+// The ways the channels can be sorted, by the name used in the requests.
+var channelSorts = map[string]string{
+	"name":         "channels.name COLLATE NOCASE ASC",
+	"likeCount":    "likeCount DESC",
+	"dislikeCount": "dislikeCount DESC",
+	"ratedCount":   "COUNT(videos.rowid) DESC",
+	"lastRatedAt":  "lastRatedAt DESC",
+}
+
+// The statistics of the channels.
+// The date of the last rating of a video is the date of its last update, or of its creation if it has never been updated.
+const channelStatsSelect = `SELECT channels.id, channels.channel_id, channels.name,
+	COUNT(CASE WHEN videos.rating = 'like' THEN 1 END) AS likeCount,
+	COUNT(CASE WHEN videos.rating = 'dislike' THEN 1 END) AS dislikeCount,
+	COUNT(CASE WHEN videos.rating = 'none' THEN 1 END) AS noneCount,
+	COALESCE(SUM(videos.duration_seconds), 0),
+	COALESCE(MIN(videos.created_at), ''),
+	COALESCE(MAX(CASE WHEN videos.updated_at != '' THEN videos.updated_at ELSE videos.created_at END), '') AS lastRatedAt
+	FROM channels LEFT JOIN videos ON videos.channel_id = channels.id AND videos.deleted_at = ''`
+
+// Get all the channels with the statistics of the ratings of their videos.
+//
+// `sort` is one of the keys of channelSorts (by name if it's empty).
+func (store *Store) GetChannels(sort string) ([]ChannelStats, error) {
+	if sort == "" {
+		sort = "name"
+	}
+	var orderBy, keyExists = channelSorts[sort]
+	if keyExists == false {
+		return nil, fmt.Errorf("The sort \"%s\" is invalid", sort)
+	}
+
+	var rows, queryErr = store.connection.Query(channelStatsSelect + " GROUP BY channels.id ORDER BY " + orderBy + ", channels.id;")
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var channels = []ChannelStats{}
+	var channelIndexesByRowid = make(map[int64]int)
+	for rows.Next() {
+		var rowid int64
+		var stats = ChannelStats{PreviousNames: []string{}}
+		if scanErr := scanChannelStats(rows, &rowid, &stats); scanErr != nil {
+			return nil, scanErr
+		}
+		channelIndexesByRowid[rowid] = len(channels)
+		channels = append(channels, stats)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	var renameRows, renamesErr = store.connection.Query("SELECT channel_id, old_name FROM channel_renames ORDER BY renamed_at, rowid;")
+	if renamesErr != nil {
+		return nil, renamesErr
+	}
+	defer renameRows.Close()
+	for renameRows.Next() {
+		var rowid int64
+		var oldName string
+		if scanErr := renameRows.Scan(&rowid, &oldName); scanErr != nil {
+			return nil, scanErr
+		}
+		if index, indexExists := channelIndexesByRowid[rowid]; indexExists {
+			channels[index].PreviousNames = append(channels[index].PreviousNames, oldName)
+		}
+	}
+	return channels, renameRows.Err()
+}
+
+// Get a channel (by its channel id), the statistics of the ratings of its videos, and its rated videos the newest first.
+//
+// Return sql.ErrNoRows if the channel is unknown.
+func (store *Store) GetChannel(channelId string) (*ChannelDetail, error) {
+	var rowid int64
+	var detail = &ChannelDetail{ChannelStats: ChannelStats{PreviousNames: []string{}}}
+	var row = store.connection.QueryRow(channelStatsSelect+" WHERE channels.channel_id = ? GROUP BY channels.id;", channelId)
+	if scanErr := scanChannelStats(row, &rowid, &detail.ChannelStats); scanErr != nil {
+		return nil, scanErr
+	}
+
+	var renameRows, renamesErr = store.connection.Query("SELECT old_name FROM channel_renames WHERE channel_id = ? ORDER BY renamed_at, rowid;", rowid)
+	if renamesErr != nil {
+		return nil, renamesErr
+	}
+	defer renameRows.Close()
+	for renameRows.Next() {
+		var oldName string
+		if scanErr := renameRows.Scan(&oldName); scanErr != nil {
+			return nil, scanErr
+		}
+		detail.PreviousNames = append(detail.PreviousNames, oldName)
+	}
+	if rowsErr := renameRows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	var videos, _, videosErr = store.GetRatedVideos(RatedVideosQuery{ChannelId: channelId, Sort: "createdAt", Descending: true, WithDetails: true})
+	if videosErr != nil {
+		return nil, videosErr
+	}
+	detail.Videos = videos
+	if detail.Videos == nil {
+		detail.Videos = []RatedVideo{}
+	}
+	return detail, nil
+}
+
+func scanChannelStats(row interface{ Scan(dest ...any) error }, rowid *int64, stats *ChannelStats) error {
+	return row.Scan(rowid, &stats.ChannelId, &stats.Name, &stats.LikeCount, &stats.DislikeCount, &stats.NoneCount,
+		&stats.TotalDurationSeconds, &stats.FirstRatedAt, &stats.LastRatedAt)
+}
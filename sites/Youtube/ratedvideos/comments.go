@@ -0,0 +1,50 @@
+package youtube
+
+import (
+	"database/sql"
+	"fmt"
+	dates "mylocalhost/utils/dates"
+)
+
+// My personal note about a rated video.
+type VideoComment struct {
+	VideoId   string `json:"videoId"`
+	Comment   string `json:"comment"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// Get my comment about the given video.
+//
+// Return sql.ErrNoRows if the video has never been rated or is deleted.
+func (store *Store) GetVideoComment(videoId string) (*VideoComment, error) {
+	var comment = &VideoComment{VideoId: videoId}
+	var scanErr = store.connection.QueryRow("SELECT comment, comment_updated_at FROM videos WHERE video_id = ? AND deleted_at = '';", videoId).Scan(&comment.Comment, &comment.UpdatedAt)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return comment, nil
+}
+
+// Set, edit or clear (with an empty comment) my comment about the given video.
+//
+// Return sql.ErrNoRows if the video has never been rated or is deleted.
+func (store *Store) SetVideoComment(videoId string, comment string) (*VideoComment, error) {
+	var stmt, stmtErr = store.connection.Prepare("UPDATE videos SET comment = ?, comment_updated_at = ? WHERE video_id = ? AND deleted_at = '';")
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+	defer stmt.Close()
+
+	var videoComment = &VideoComment{VideoId: videoId, Comment: comment, UpdatedAt: dates.NowToString()}
+	var result, execErr = stmt.Exec(videoComment.Comment, videoComment.UpdatedAt, videoId)
+	if execErr != nil {
+		return nil, execErr
+	}
+	var rowsAffected, _ = result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, sql.ErrNoRows
+	} else if rowsAffected > 1 {
+		return nil, fmt.Errorf("The update of the comment for the video %s has affected %d rows", videoId, rowsAffected)
+	}
+	return videoComment, nil
+}
@@ -3,9 +3,10 @@ package youtube
 import (
 	"database/sql"
 	"fmt"
-	"mylocalhost/config"
+	cache "mylocalhost/utils/cache"
 	database "mylocalhost/utils/database"
 	dates "mylocalhost/utils/dates"
+	"sync"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -14,182 +15,265 @@ type RatedVideo struct {
 	Rowid   int64  `json:"-"`
 	VideoId string `json:"videoId"`
 	Rating  string `json:"rating"`
+	// My note about the video.
+	Comment string `json:"comment,omitempty"`
+
+	//.. The details are only sent when they are asked.
+	Title           string `json:"title,omitempty"`
+	ChannelName     string `json:"channelName,omitempty"`
+	ChannelId       string `json:"channelId,omitempty"`
+	Description     string `json:"description,omitempty"`
+	DurationSeconds int64  `json:"durationSeconds,omitempty"`
+	CreatedAt       string `json:"createdAt,omitempty"`
+	UpdatedAt       string `json:"updatedAt,omitempty"`
+
+	CommentUpdatedAt string `json:"commentUpdatedAt,omitempty"`
+	DownloadedAt     string `json:"downloadedAt,omitempty"`
+	DeletedAt        string `json:"deletedAt,omitempty"`
+
+	// The number of the last change of the video (see SyncState).
+	changeSeq int64
 }
 
-var _connection *sql.DB
+// The rated videos of a database, and the caches of their ratings.
+type Store struct {
+	connection *sql.DB
 
-// Cache of the videos I just rated.
-var _videosByVideoId = make(map[string]*RatedVideo)
-var _channelIdsByName = make(map[string]int64)
+	cacheMode string
+	// The videos I rated, by video id. It's only used if the cache mode isn't off.
+	videosCache *cache.LRU[string, RatedVideo]
+	// The channels, by channel id.
+	channelsCache *cache.LRU[string, channel]
+	// Held while a transaction is committed and its changes are applied to the caches.
+	commitMutex sync.Mutex
+}
 
-func openConnection() error {
-	if _connection != nil {
-		return nil
+// Open the database and bring its schema up to date. The store must be closed once it's no longer used.
+func OpenStore(dbFilePath string, connectionOptions database.ConnectionOptions, cacheOptions CacheOptions) (*Store, error) {
+	var connection, _, connectionErr = database.OpenSQLiteConnection(dbFilePath, connectionOptions)
+	if connectionErr != nil {
+		return nil, connectionErr
 	}
 
-	var dbFilePath = config.Get("Youtube.ratedVideos.databaseFilePath")
+	if ftsErr := checkFTS5(connection); ftsErr != nil {
+		connection.Close()
+		return nil, ftsErr
+	}
 
-	var connection, dbFileExists, connectionErr = database.OpenSQLiteConnection(dbFilePath)
-	if connectionErr != nil {
-		return connectionErr
-	}
-	_connection = connection
-
-	if dbFileExists == false {
-		var _, execErr = connection.Exec(`
-		CREATE TABLE "channels" ("id" INTEGER, "name" TEXT NOT NULL CHECK("name" != '') UNIQUE, "channel_id" TEXT NOT NULL CHECK("channel_id" != ''),
-		PRIMARY KEY("id"));
-		
-		CREATE TABLE "videos" ("video_id" TEXT NOT NULL CHECK("video_id" != '') UNIQUE, "rating" TEXT NOT NULL CHECK("rating" != ''), 
-		"channel_id" INTEGER NOT NULL, "title" TEXT NOT NULL CHECK("title" != ''),
-		"description" TEXT NOT NULL DEFAULT '', "comment" TEXT NOT NULL DEFAULT '',
-		"created_at" TEXT NOT NULL CHECK("created_at" != ''), "updated_at" TEXT NOT NULL DEFAULT '',
-		"downloaded_at" TEXT NOT NULL DEFAULT '', "deleted_at" TEXT NOT NULL DEFAULT '',
-		FOREIGN KEY("channel_id") REFERENCES "channels"("id") ON DELETE RESTRICT ON UPDATE CASCADE);
-		
-		CREATE INDEX "idx_channels_name" ON "channels" ("name");
-		CREATE INDEX "idx_videos_video_id" ON "videos" ("video_id");`)
-		if execErr != nil {
-			return execErr
-		}
+	if migrateErr := database.Migrate(connection, "Youtube.ratedVideos", migrations); migrateErr != nil {
+		connection.Close()
+		return nil, migrateErr
 	}
 
+	var store = &Store{connection: connection}
+	if cacheErr := store.initCaches(cacheOptions); cacheErr != nil {
+		connection.Close()
+		return nil, cacheErr
+	}
+	return store, nil
+}
+
+// Check that SQLite has the full-text search, needed by the migrations and the search of the videos.
+func checkFTS5(connection *sql.DB) error {
+	var ftsEnabled bool
+	if scanErr := connection.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5');").Scan(&ftsEnabled); scanErr != nil {
+		return scanErr
+	}
+	if ftsEnabled == false {
+		return fmt.Errorf("The full-text search of SQLite (FTS5) isn't available: the program must be built with \"go build -tags sqlite_fts5\"")
+	}
 	return nil
 }
 
-func GetRatedVideos() ([]RatedVideo, error) {
-	if openErr := openConnection(); openErr != nil {
-		return nil, openErr
+// Get the rated videos matching the given query, and the cursor of the next page (empty if it's the last one).
+func (store *Store) GetRatedVideos(query RatedVideosQuery) ([]RatedVideo, string, error) {
+	var sqlQuery, args, queryErr = query.toSQL()
+	if queryErr != nil {
+		return nil, "", queryErr
 	}
 
-	var stmt, stmtErr = _connection.Prepare("SELECT video_id, rating FROM videos;")
+	var stmt, stmtErr = store.connection.Prepare(sqlQuery)
 	if stmtErr != nil {
-		return nil, stmtErr
+		return nil, "", stmtErr
 	}
 	defer stmt.Close()
 
-	var rows, queryErr = stmt.Query()
-	if queryErr != nil {
-		return nil, queryErr
+	var rows, rowsErr = stmt.Query(args...)
+	if rowsErr != nil {
+		return nil, "", rowsErr
 	}
 	defer rows.Close()
 
 	var ratedVideos []RatedVideo
 	for rows.Next() {
 		var ratedVideo = RatedVideo{}
-		if scanErr := rows.Scan(&ratedVideo.VideoId, &ratedVideo.Rating); scanErr != nil {
-			return nil, scanErr
+		if scanErr := rows.Scan(&ratedVideo.Rowid, &ratedVideo.VideoId, &ratedVideo.Rating, &ratedVideo.Title, &ratedVideo.ChannelName, &ratedVideo.ChannelId,
+			&ratedVideo.Description, &ratedVideo.DurationSeconds, &ratedVideo.CreatedAt, &ratedVideo.UpdatedAt, &ratedVideo.Comment, &ratedVideo.CommentUpdatedAt, &ratedVideo.DownloadedAt, &ratedVideo.DeletedAt, &ratedVideo.changeSeq); scanErr != nil {
+			return nil, "", scanErr
 		}
 		ratedVideos = append(ratedVideos, ratedVideo)
 	}
-
-	return ratedVideos, nil
-}
-
-// Insert or update the rating for a video.
-func SetVideoRating(videoId string, rating string, channelName string, videoTitle string, channelId string, videoDescription string, videoDurationSeconds int64) error {
-	if openErr := openConnection(); openErr != nil {
-		return openErr
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, "", rowsErr
 	}
 
-	var ratedVideo, err = getVideoFromVideoId(videoId)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			err = insertVideo(videoId, rating, channelName, videoTitle, channelId, videoDescription, videoDurationSeconds)
+	var nextCursor = ""
+	if query.Limit > 0 && len(ratedVideos) > query.Limit {
+		ratedVideos = ratedVideos[:query.Limit]
+		var cursorErr error
+		nextCursor, cursorErr = query.nextCursor(&ratedVideos[query.Limit-1])
+		if cursorErr != nil {
+			return nil, "", cursorErr
 		}
-	} else if ratedVideo.Rating != rating {
-		err = updateRating(ratedVideo, rating)
 	}
-	return err
-}
 
-func getVideoFromVideoId(videoid string) (*RatedVideo, error) {
-	var cacheVideoRankings = config.GetBoolean("Youtube.ratedVideos.cacheVideoRankings", false)
-	if cacheVideoRankings {
-		var video, keyExists = _videosByVideoId[videoid]
-		if keyExists {
-			return video, nil
+	if query.WithDetails == false {
+		for i := range ratedVideos {
+			ratedVideos[i] = RatedVideo{Rowid: ratedVideos[i].Rowid, VideoId: ratedVideos[i].VideoId, Rating: ratedVideos[i].Rating, Comment: ratedVideos[i].Comment, DeletedAt: ratedVideos[i].DeletedAt,
+				changeSeq: ratedVideos[i].changeSeq}
 		}
 	}
 
-	var stmt, stmtErr = _connection.Prepare("SELECT rowid, rating FROM videos WHERE video_id = ?")
-	if stmtErr != nil {
-		return nil, stmtErr
+	return ratedVideos, nextCursor, nil
+}
+
+// Insert or update the rating for a video.
+func (store *Store) SetVideoRating(videoId string, rating string, channelName string, videoTitle string, channelId string, videoDescription string, videoDurationSeconds int64) error {
+	var transaction, transactionErr = store.begin()
+	if transactionErr != nil {
+		return transactionErr
 	}
-	defer stmt.Close()
+	defer transaction.Rollback()
 
-	var ratedVideo = &RatedVideo{}
-	var scanErr = stmt.QueryRow(videoid).Scan(&ratedVideo.Rowid, &ratedVideo.Rating)
-	if scanErr == nil && cacheVideoRankings {
-		_videosByVideoId[videoid] = ratedVideo
+	var _, err = store.rateVideo(transaction, &videoToRate{VideoId: videoId, Rating: rating, ChannelName: channelName, Title: videoTitle, ChannelId: channelId, Description: videoDescription, DurationSeconds: videoDurationSeconds})
+	if err != nil {
+		return err
 	}
-	return ratedVideo, scanErr
+	return transaction.commit()
+}
+
+// The data of a video I rated.
+type videoToRate struct {
+	VideoId         string
+	Rating          string
+	ChannelName     string
+	ChannelId       string
+	Title           string
+	Description     string
+	DurationSeconds int64
+	// When I rated the video, if it's not now (formatted like the dates in database).
+	RatedAt string
 }
 
-func insertVideo(videoid string, rating string, channelName string, videoTitle string, channelId string, videoDescription string, videoDurationSeconds int64) error {
-	var channelRowid, err = getChannelIdByName(channelName)
+// What rateVideo has done.
+const (
+	rateInserted  = "inserted"
+	rateUpdated   = "updated"
+	rateUnchanged = "unchanged"
+	// The video has a different rating, saved after the given one, which is therefore ignored.
+	rateOutdated = "outdated"
+)
+
+// Insert or update the rating for a video in the given transaction, and return what has been done.
+//
+// A rating older than the one saved never overwrites it.
+func (store *Store) rateVideo(transaction *cachedTx, video *videoToRate) (string, error) {
+	var ratedVideo, err = store.getVideoFromVideoId(transaction, video.VideoId)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			channelRowid, err = insertChannel(channelName, channelId)
-			if err != nil {
-				return err
+			if insertErr := store.insertVideo(transaction, video); insertErr != nil {
+				return "", insertErr
 			}
-		} else {
-			return err
+			return rateInserted, nil
 		}
+		return "", err
 	}
 
-	var stmt, stmtErr = _connection.Prepare("INSERT INTO videos(video_id, rating, channel_id, title, description, duration_seconds, created_at) VALUES(?, ?, ?, ?, ?, ?, ?);")
-	if stmtErr != nil {
-		return stmtErr
+	if video.RatedAt != "" && video.RatedAt < ratedVideo.lastRatedAt() {
+		if ratedVideo.Rating == video.Rating && ratedVideo.DeletedAt == "" {
+			return rateUnchanged, nil
+		}
+		return rateOutdated, nil
 	}
-	defer stmt.Close()
+	if ratedVideo.Rating != video.Rating || ratedVideo.DeletedAt != "" {
+		//.. If I rate again a video I had deleted, it's restored.
+		if updateErr := store.updateRating(transaction, ratedVideo, video.Rating, video.RatedAt); updateErr != nil {
+			return "", updateErr
+		}
+		return rateUpdated, nil
+	}
+	return rateUnchanged, nil
+}
 
-	var result, execErr = stmt.Exec(videoid, rating, channelRowid, videoTitle, videoDescription, videoDurationSeconds, dates.NowToString())
-	_ = result
-	return execErr
+// The date of the last time I rated the video.
+func (video *RatedVideo) lastRatedAt() string {
+	if video.UpdatedAt != "" {
+		return video.UpdatedAt
+	}
+	return video.CreatedAt
 }
 
-func getChannelIdByName(name string) (int64, error) {
-	var channelId, keyExists = _channelIdsByName[name]
-	if keyExists {
-		return channelId, nil
+func (store *Store) getVideoFromVideoId(transaction *cachedTx, videoid string) (*RatedVideo, error) {
+	//.. The cache keeps a copy, so the video can be changed without changing the cache.
+	if video, keyExists := transaction.getCachedVideo(videoid); keyExists {
+		return &video, nil
 	}
 
-	var stmt, stmtErr = _connection.Prepare("SELECT id FROM channels WHERE name = ?")
+	var stmt, stmtErr = transaction.Prepare("SELECT rowid, rating, created_at, updated_at, deleted_at FROM videos WHERE video_id = ?")
 	if stmtErr != nil {
-		return 0, stmtErr
+		return nil, stmtErr
 	}
 	defer stmt.Close()
 
-	var scanErr = stmt.QueryRow(name).Scan(&channelId)
-	return channelId, scanErr
+	var ratedVideo = &RatedVideo{VideoId: videoid}
+	var scanErr = stmt.QueryRow(videoid).Scan(&ratedVideo.Rowid, &ratedVideo.Rating, &ratedVideo.CreatedAt, &ratedVideo.UpdatedAt, &ratedVideo.DeletedAt)
+	if scanErr == nil {
+		//.. The video read isn't put in cache, since a newer rating may be committed before this transaction.
+		transaction.evictVideo(videoid)
+	}
+	return ratedVideo, scanErr
 }
 
-func insertChannel(name string, id string) (int64, error) {
-	var stmt, stmtErr = _connection.Prepare("INSERT INTO channels(name, channel_id) VALUES(?, ?);")
+func (store *Store) insertVideo(transaction *cachedTx, video *videoToRate) error {
+	//.. A channel is only renamed by a rating made now, since the name given with an older rating may be an old one.
+	var channelRowid, err = store.saveChannel(transaction, video.ChannelId, video.ChannelName, video.RatedAt == "")
+	if err != nil {
+		return err
+	}
+
+	var stmt, stmtErr = transaction.Prepare("INSERT INTO videos(video_id, rating, channel_id, title, description, duration_seconds, created_at) VALUES(?, ?, ?, ?, ?, ?, ?);")
 	if stmtErr != nil {
-		return 0, stmtErr
+		return stmtErr
 	}
 	defer stmt.Close()
 
-	var result, execErr = stmt.Exec(name, id)
+	var createdAt = video.RatedAt
+	if createdAt == "" {
+		createdAt = dates.NowToString()
+	}
+	var result, execErr = stmt.Exec(video.VideoId, video.Rating, channelRowid, video.Title, video.Description, video.DurationSeconds, createdAt)
 	if execErr != nil {
-		return 0, execErr
+		return execErr
 	}
-	var lastInsertId, _ = result.LastInsertId()
-	_channelIdsByName[name] = lastInsertId
-	return lastInsertId, nil
+	var rowid, _ = result.LastInsertId()
+	transaction.cacheVideo(&RatedVideo{VideoId: video.VideoId, Rowid: rowid, Rating: video.Rating, CreatedAt: createdAt})
+	return nil
 }
 
-func updateRating(ratedVideo *RatedVideo, rating string) error {
-	var stmt, stmtErr = _connection.Prepare("UPDATE videos SET rating = ?, updated_at = ? WHERE rowid = ?;")
+// Update the rating of the video, and keep the previous one in the history of its ratings.
+//
+// The video is restored if it was deleted. `updatedAt` is now if it's empty.
+func (store *Store) updateRating(transaction *cachedTx, ratedVideo *RatedVideo, rating string, updatedAt string) error {
+	var stmt, stmtErr = transaction.Prepare("UPDATE videos SET rating = ?, updated_at = ?, deleted_at = '' WHERE rowid = ?;")
 	if stmtErr != nil {
 		return stmtErr
 	}
 	defer stmt.Close()
 
-	var result, execErr = stmt.Exec(rating, dates.NowToString(), ratedVideo.Rowid)
+	if updatedAt == "" {
+		updatedAt = dates.NowToString()
+	}
+	var result, execErr = stmt.Exec(rating, updatedAt, ratedVideo.Rowid)
 	if execErr != nil {
 		return execErr
 	}
@@ -199,12 +283,19 @@ func updateRating(ratedVideo *RatedVideo, rating string) error {
 	} else if rowsAffected > 1 {
 		return fmt.Errorf("The update of the rating \"%s\" for the rowid %d has affected %d rows", rating, ratedVideo.Rowid, rowsAffected)
 	}
+
+	if ratedVideo.Rating != rating {
+		if insertErr := insertRatingUpdate(transaction.Tx, ratedVideo.VideoId, ratedVideo.Rating, rating, updatedAt); insertErr != nil {
+			return insertErr
+		}
+	}
 	ratedVideo.Rating = rating
+	ratedVideo.UpdatedAt = updatedAt
+	ratedVideo.DeletedAt = ""
+	transaction.cacheVideo(ratedVideo)
 	return nil
 }
 
-func CloseDatabaseConnection() {
-	if _connection != nil {
-		_connection.Close()
-	}
+func (store *Store) Close() error {
+	return store.connection.Close()
 }
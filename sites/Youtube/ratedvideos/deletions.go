@@ -0,0 +1,99 @@
+package youtube
+
+import (
+	"database/sql"
+	"fmt"
+	dates "mylocalhost/utils/dates"
+	"time"
+)
+
+// Delete the given video. It's only marked as deleted, so it can be restored until it's purged.
+//
+// Return sql.ErrNoRows if the video has never been rated or is already deleted.
+func (store *Store) DeleteVideo(videoId string) error {
+	return store.setDeletedAt(videoId, dates.NowToString(), "deleted_at = ''")
+}
+
+// Restore the given deleted video.
+//
+// Return sql.ErrNoRows if the video has never been rated or isn't deleted.
+func (store *Store) RestoreVideo(videoId string) error {
+	return store.setDeletedAt(videoId, "", "deleted_at != ''")
+}
+
+// Set the deletion date of the video, and evict it from the cache once it's committed.
+func (store *Store) setDeletedAt(videoId string, deletedAt string, condition string) error {
+	var transaction, transactionErr = store.begin()
+	if transactionErr != nil {
+		return transactionErr
+	}
+	defer transaction.Rollback()
+
+	var stmt, stmtErr = transaction.Prepare("UPDATE videos SET deleted_at = ? WHERE video_id = ? AND " + condition + ";")
+	if stmtErr != nil {
+		return stmtErr
+	}
+	defer stmt.Close()
+
+	var result, execErr = stmt.Exec(deletedAt, videoId)
+	if execErr != nil {
+		return execErr
+	}
+	var rowsAffected, _ = result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	} else if rowsAffected > 1 {
+		return fmt.Errorf("The update of the deletion of the video %s has affected %d rows", videoId, rowsAffected)
+	}
+	transaction.evictVideo(videoId)
+	return transaction.commit()
+}
+
+// Delete for good the videos deleted for longer than the given duration, with the history of their ratings.
+//
+// Return the number of videos purged.
+func (store *Store) PurgeDeletedVideos(deletedFor time.Duration) (int, error) {
+	var deletedBefore = dates.ToString(time.Now().Add(-deletedFor))
+
+	var transaction, transactionErr = store.begin()
+	if transactionErr != nil {
+		return 0, transactionErr
+	}
+	defer transaction.Rollback()
+
+	var rows, queryErr = transaction.Query("SELECT video_id FROM videos WHERE deleted_at != '' AND deleted_at < ?;", deletedBefore)
+	if queryErr != nil {
+		return 0, queryErr
+	}
+	var videoIds []string
+	for rows.Next() {
+		var videoId string
+		if scanErr := rows.Scan(&videoId); scanErr != nil {
+			rows.Close()
+			return 0, scanErr
+		}
+		videoIds = append(videoIds, videoId)
+	}
+	rows.Close()
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return 0, rowsErr
+	}
+
+	if setErr := setPurgedChangeSeq(transaction.Tx, videoIds); setErr != nil {
+		return 0, setErr
+	}
+	for _, videoId := range videoIds {
+		if _, execErr := transaction.Exec("DELETE FROM rating_updates WHERE video_id = ?;", videoId); execErr != nil {
+			return 0, execErr
+		}
+		if _, execErr := transaction.Exec("DELETE FROM videos WHERE video_id = ?;", videoId); execErr != nil {
+			return 0, execErr
+		}
+		transaction.evictVideo(videoId)
+	}
+
+	if commitErr := transaction.commit(); commitErr != nil {
+		return 0, commitErr
+	}
+	return len(videoIds), nil
+}
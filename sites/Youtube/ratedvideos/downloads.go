@@ -0,0 +1,125 @@
+package youtube
+
+import (
+	"fmt"
+	"io"
+	dates "mylocalhost/utils/dates"
+	"strings"
+)
+
+// A liked video I haven't downloaded yet.
+type PendingDownload struct {
+	VideoId         string `json:"videoId"`
+	Title           string `json:"title"`
+	ChannelName     string `json:"channelName"`
+	DurationSeconds int64  `json:"durationSeconds"`
+	CreatedAt       string `json:"createdAt"`
+}
+
+func (download *PendingDownload) Url() string {
+	return "https://www.youtube.com/watch?v=" + download.VideoId
+}
+
+// Mark the given videos as downloaded now, or clear that mark.
+//
+// Return the ids of the videos which have never been rated or are deleted. The other videos are updated in the same transaction.
+func (store *Store) SetVideosDownloaded(videoIds []string, downloaded bool) ([]string, error) {
+	var transaction, transactionErr = store.connection.Begin()
+	if transactionErr != nil {
+		return nil, transactionErr
+	}
+	defer transaction.Rollback()
+
+	var stmt, stmtErr = transaction.Prepare("UPDATE videos SET downloaded_at = ? WHERE video_id = ? AND deleted_at = '';")
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+	defer stmt.Close()
+
+	var downloadedAt = ""
+	if downloaded {
+		downloadedAt = dates.NowToString()
+	}
+
+	var unknownVideoIds = []string{}
+	for _, videoId := range videoIds {
+		var result, execErr = stmt.Exec(downloadedAt, videoId)
+		if execErr != nil {
+			return nil, execErr
+		}
+		var rowsAffected, _ = result.RowsAffected()
+		if rowsAffected == 0 {
+			unknownVideoIds = append(unknownVideoIds, videoId)
+		} else if rowsAffected > 1 {
+			return nil, fmt.Errorf("The update of the download of the video %s has affected %d rows", videoId, rowsAffected)
+		}
+	}
+
+	if commitErr := transaction.Commit(); commitErr != nil {
+		return nil, commitErr
+	}
+	return unknownVideoIds, nil
+}
+
+// Get the liked videos not downloaded yet, in the order I liked them.
+func (store *Store) GetPendingDownloads() ([]PendingDownload, error) {
+	var stmt, stmtErr = store.connection.Prepare(`SELECT videos.video_id, videos.title, channels.name, videos.duration_seconds, videos.created_at
+		FROM videos INNER JOIN channels ON channels.id = videos.channel_id
+		WHERE videos.rating = 'like' AND videos.downloaded_at = '' AND videos.deleted_at = ''
+		ORDER BY videos.created_at, videos.rowid;`)
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+	defer stmt.Close()
+
+	var rows, queryErr = stmt.Query()
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var downloads = []PendingDownload{}
+	for rows.Next() {
+		var download = PendingDownload{}
+		if scanErr := rows.Scan(&download.VideoId, &download.Title, &download.ChannelName, &download.DurationSeconds, &download.CreatedAt); scanErr != nil {
+			return nil, scanErr
+		}
+		downloads = append(downloads, download)
+	}
+	return downloads, rows.Err()
+}
+
+// Write the videos as a batch file for yt-dlp (option --batch-file): one url per line.
+func WriteYtDlpBatchFile(w io.Writer, downloads []PendingDownload) error {
+	for _, download := range downloads {
+		//.. yt-dlp ignores the lines starting with "#", so I write the title to know what the urls are.
+		var _, writeErr = fmt.Fprintf(w, "# %s - %s\n%s\n", oneLine(download.ChannelName), oneLine(download.Title), download.Url())
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+	return nil
+}
+
+// Write the videos as an extended M3U playlist.
+func WriteM3UPlaylist(w io.Writer, downloads []PendingDownload) error {
+	if _, writeErr := io.WriteString(w, "#EXTM3U\n"); writeErr != nil {
+		return writeErr
+	}
+	for _, download := range downloads {
+		var duration = download.DurationSeconds
+		if duration <= 0 {
+			duration = -1
+		}
+		var _, writeErr = fmt.Fprintf(w, "#EXTINF:%d,%s - %s\n%s\n", duration, oneLine(download.ChannelName), oneLine(download.Title), download.Url())
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+	return nil
+}
+
+// Replace the line breaks of the text, which would break the lines of a playlist.
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -2,18 +2,60 @@ package youtube
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
+	dates "mylocalhost/utils/dates"
 	responses "mylocalhost/utils/responses"
+	validation "mylocalhost/utils/validation"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // My Chrome extension wants to get all the videos and their rating from database.
-func GetRatedVideosRequestHandler(w http.ResponseWriter, r *http.Request) {
+//
+// The videos can be filtered, sorted and paginated with the query parameters:
+// rating, channel (name or id), channelId, createdFrom, createdTo, updatedFrom, updatedTo, title, withComment=true, includeDeleted=true,
+// sort (createdAt/updatedAt/title/channelName/durationSeconds), order (asc/desc), limit, cursor
+// and details=true to get the title, channel, description, duration and dates of the videos.
+// When there is a next page, its cursor is sent in the header "X-Next-Cursor".
+//
+// A sync token is sent in the header "X-Sync-Token". Given in the query parameter "since",
+// only the videos inserted, updated or deleted (with their "deletedAt") since then are sent, in the order of their changes.
+// With a limit, the token of a page which isn't the last one is the one of its last video, so "since=0" gets all the videos page by page.
+// If the token is too old, the response is 410 Gone and all the videos must be got again.
+// The header "ETag" is sent too, and the response is 304 Not Modified if nothing has changed since the "If-None-Match" one.
+func (store *Store) GetRatedVideosRequestHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var videos, videosErr = GetRatedVideos()
+	var query, queryErr = parseRatedVideosQuery(r)
+	if queryErr != nil {
+		responses.SendErrorResponse(w, http.StatusBadRequest, queryErr, "Parsing the query parameters")
+		return
+	}
+
+	var syncState, syncStateErr = store.GetSyncState()
+	if syncStateErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, syncStateErr, "Getting the sync state from database")
+		return
+	}
+	w.Header().Set("ETag", syncState.ETag())
+	w.Header().Set("X-Sync-Token", syncState.Token())
+	if r.Header.Get("If-None-Match") == syncState.ETag() {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if query.SyncToken != "" {
+		if tokenErr := syncState.CheckToken(query.SyncToken); tokenErr != nil {
+			responses.SendErrorResponse(w, http.StatusGone, tokenErr, "Checking the sync token")
+			return
+		}
+	}
+
+	var videos, nextCursor, videosErr = store.GetRatedVideos(query)
 	if videosErr != nil {
 		responses.SendErrorResponse(w, http.StatusInternalServerError, videosErr, "Getting the rated videos from database")
 		return
@@ -21,146 +63,558 @@ func GetRatedVideosRequestHandler(w http.ResponseWriter, r *http.Request) {
 
 	var buffer bytes.Buffer
 	if encodeErr := json.NewEncoder(&buffer).Encode(videos); encodeErr == nil {
+		if nextCursor != "" {
+			w.Header().Set("X-Next-Cursor", nextCursor)
+			//.. The videos of the next pages may change before they are got, so the token mustn't go past them.
+			if query.SyncToken != "" {
+				w.Header().Set("X-Sync-Token", strconv.FormatInt(videos[len(videos)-1].changeSeq, 10))
+			}
+		}
 		buffer.WriteTo(w)
 	} else {
 		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the rated videos in JSON")
 	}
 }
 
-// I just rated a Youtube video. My Chrome extension intercepted that and sent some video data to save them in database.
-func SetVideoRatingRequestHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+func parseRatedVideosQuery(r *http.Request) (RatedVideosQuery, error) {
+	var values = r.URL.Query()
+	var query = RatedVideosQuery{
+		Rating:         values.Get("rating"),
+		Channel:        values.Get("channel"),
+		ChannelId:      values.Get("channelId"),
+		CreatedFrom:    values.Get("createdFrom"),
+		CreatedTo:      values.Get("createdTo"),
+		UpdatedFrom:    values.Get("updatedFrom"),
+		UpdatedTo:      values.Get("updatedTo"),
+		Title:          values.Get("title"),
+		WithComment:    values.Get("withComment") == "true",
+		IncludeDeleted: values.Get("includeDeleted") == "true",
+		Sort:           values.Get("sort"),
+		Cursor:         values.Get("cursor"),
+		SyncToken:      values.Get("since"),
+		WithDetails:    values.Get("details") == "true",
+	}
 
-	if r.Method != "POST" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
-		return
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, fmt.Errorf("The order is invalid (should be either asc/desc)")
 	}
 
-	var requestBody, requestBodyErr = io.ReadAll(r.Body)
-	if requestBodyErr != nil {
-		responses.SendErrorResponse(w, http.StatusInternalServerError, requestBodyErr, "Reading POST data")
+	if limit := values.Get("limit"); limit != "" {
+		var limitValue, convErr = strconv.Atoi(limit)
+		if convErr != nil {
+			return query, fmt.Errorf("The limit is not a integer")
+		}
+		query.Limit = limitValue
+	}
+
+	var validateErr = query.validate()
+	return query, validateErr
+}
+
+// Search some terms in the title and description of the rated videos.
+//
+// Query parameters: q (the terms, all of them must match, "term*" for a prefix), rating, channel (name or id), includeDeleted=true, limit (50 by default).
+func (store *Store) SearchRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var values = r.URL.Query()
+	var terms = values.Get("q")
+	if toMatchExpression(terms) == "" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No search terms given (q)")
 		return
 	}
-	if len(requestBody) == 0 {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The POST data is empty")
+	var rating = values.Get("rating")
+	if rating != "" && rating != "like" && rating != "dislike" && rating != "none" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The rating is invalid (should be either like/dislike/none)")
 		return
 	}
+	var limit = 50
+	if limitValue := values.Get("limit"); limitValue != "" {
+		var convErr error
+		limit, convErr = strconv.Atoi(limitValue)
+		if convErr != nil || limit <= 0 {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The limit is not a positive integer")
+			return
+		}
+	}
 
-	var postData map[string]interface{}
-	if parseErr := json.Unmarshal(requestBody, &postData); parseErr != nil {
-		responses.SendErrorResponse(w, http.StatusBadRequest, parseErr, "Parsing the POST data to JSON")
+	var results, searchErr = store.SearchRatedVideos(terms, rating, values.Get("channel"), values.Get("includeDeleted") == "true", limit)
+	if searchErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, searchErr, "Searching the rated videos in database")
 		return
 	}
 
-	videoIdValue, keyExists := postData["videoId"]
-	if keyExists == false {
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(results); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the search results in JSON")
+	}
+}
+
+// Get all the changes of the rating of a video (query parameter: videoId).
+func (store *Store) GetRatingHistoryRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var videoId = r.URL.Query().Get("videoId")
+	if videoId == "" {
 		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoId given")
 		return
 	}
-	videoId, typeOk := videoIdValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoId is not a string")
+
+	var history, historyErr = store.GetRatingHistory(videoId)
+	if historyErr != nil {
+		if historyErr == sql.ErrNoRows {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The video has never been rated")
+		} else {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, historyErr, "Getting the rating history from database")
+		}
 		return
 	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(history); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the rating history in JSON")
+	}
+}
+
+// Get my comment about a video (query parameter: videoId).
+func (store *Store) GetVideoCommentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var videoId = r.URL.Query().Get("videoId")
 	if videoId == "" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoId is empty")
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoId given")
 		return
 	}
 
-	ratingValue, keyExists := postData["rating"]
-	if keyExists == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No rating given")
+	var comment, commentErr = store.GetVideoComment(videoId)
+	sendVideoComment(w, comment, commentErr, "Getting the comment from database")
+}
+
+// Set, edit or clear my comment about a video. The POST data is like: {"videoId": "...", "comment": "..."}
+//
+// An empty comment clears it.
+func (store *Store) SetVideoCommentRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
 		return
 	}
-	rating, typeOk := ratingValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The rating is not a string")
+
+	var postData struct {
+		VideoId string `json:"videoId" validate:"required,nonempty"`
+		Comment string `json:"comment" validate:"required"`
+	}
+	if statusCode, decodeErr := validation.DecodeRequestBody(r, &postData); decodeErr != nil {
+		responses.SendRequestErrorResponse(w, statusCode, decodeErr, "Decoding the POST data")
 		return
 	}
-	if rating == "" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The rating is empty")
+
+	var comment, commentErr = store.SetVideoComment(postData.VideoId, strings.TrimSpace(postData.Comment))
+	sendVideoComment(w, comment, commentErr, "Saving the comment in database")
+}
+
+func sendVideoComment(w http.ResponseWriter, comment *VideoComment, commentErr error, operation string) {
+	if commentErr != nil {
+		if commentErr == sql.ErrNoRows {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The video has never been rated")
+		} else {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, commentErr, operation)
+		}
 		return
 	}
-	if rating != "like" && rating != "dislike" && rating != "none" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The rating is invalid (should be either like/dislike/none)")
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(comment); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the comment in JSON")
+	}
+}
+
+// Mark some videos as downloaded, or clear that mark. The POST data is like: {"videoIds": ["...", "..."], "downloaded": true}
+//
+// The response contains the ids of the videos which have never been rated.
+func (store *Store) SetVideosDownloadedRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
 		return
 	}
 
-	channelNameValue, keyExists := postData["channelName"]
-	if keyExists == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No channelName given")
+	var postData struct {
+		VideoIds   []string `json:"videoIds" validate:"required,nonempty"`
+		Downloaded bool     `json:"downloaded" validate:"required"`
+	}
+	if statusCode, decodeErr := validation.DecodeRequestBody(r, &postData); decodeErr != nil {
+		responses.SendRequestErrorResponse(w, statusCode, decodeErr, "Decoding the POST data")
 		return
 	}
-	channelName, typeOk := channelNameValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The channelName is not a string")
+
+	var unknownVideoIds, sqlErr = store.SetVideosDownloaded(postData.VideoIds, postData.Downloaded)
+	if sqlErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, sqlErr, "Saving the downloads in database")
 		return
 	}
-	if channelName == "" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The channelName is empty")
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(map[string]any{"unknownVideoIds": unknownVideoIds}); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the unknown videos in JSON")
+	}
+}
+
+// Get the liked videos not downloaded yet, for my archiving scripts.
+//
+// The query parameter "format" can be json (by default), yt-dlp (a batch file for its option --batch-file) or m3u.
+func (store *Store) GetPendingDownloadsRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var format = r.URL.Query().Get("format")
+	var write func(w io.Writer, downloads []PendingDownload) error
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		write = func(w io.Writer, downloads []PendingDownload) error {
+			return json.NewEncoder(w).Encode(downloads)
+		}
+	case "yt-dlp":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="pending-downloads.txt"`)
+		write = WriteYtDlpBatchFile
+	case "m3u":
+		w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="pending-downloads.m3u"`)
+		write = WriteM3UPlaylist
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The format is invalid (should be either json/yt-dlp/m3u)")
 		return
 	}
 
-	videoTitleValue, keyExists := postData["videoTitle"]
-	if keyExists == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoTitle given")
+	var downloads, downloadsErr = store.GetPendingDownloads()
+	if downloadsErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Del("Content-Disposition")
+		responses.SendErrorResponse(w, http.StatusInternalServerError, downloadsErr, "Getting the pending downloads from database")
 		return
 	}
-	videoTitle, typeOk := videoTitleValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "the videoTitle is not a string")
+
+	var buffer bytes.Buffer
+	if writeErr := write(&buffer, downloads); writeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Del("Content-Disposition")
+		responses.SendErrorResponse(w, http.StatusInternalServerError, writeErr, "Writing the pending downloads")
+	}
+}
+
+// Delete a video from database (query parameter: videoId). The request must be DELETE.
+//
+// The video is only marked as deleted, it can be restored until it's purged.
+func (store *Store) DeleteVideoRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "DELETE" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be DELETE")
 		return
 	}
-	if videoTitle == "" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoTitle is empty")
+	var videoId = r.URL.Query().Get("videoId")
+	if videoId == "" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoId given")
 		return
 	}
 
-	channelIdValue, keyExists := postData["channelId"]
-	if keyExists == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No channelId given")
+	if deleteErr := store.DeleteVideo(videoId); deleteErr != nil {
+		if deleteErr == sql.ErrNoRows {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The video has never been rated or is already deleted")
+		} else {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, deleteErr, "Deleting the video in database")
+		}
+	}
+}
+
+// Restore a deleted video (query parameter: videoId). The request must be POST.
+func (store *Store) RestoreVideoRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
 		return
 	}
-	channelId, typeOk := channelIdValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The channelId is not a string")
+	var videoId = r.URL.Query().Get("videoId")
+	if videoId == "" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoId given")
 		return
 	}
+
+	if restoreErr := store.RestoreVideo(videoId); restoreErr != nil {
+		if restoreErr == sql.ErrNoRows {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The video has never been rated or isn't deleted")
+		} else {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, restoreErr, "Restoring the video in database")
+		}
+	}
+}
+
+// Get the channels with the statistics of their ratings: /youtube/channels
+//
+// The query parameter "sort" can be name (by default), likeCount, dislikeCount, ratedCount or lastRatedAt.
+//
+// Get a channel, its statistics and its rated videos: /youtube/channels/{channelId}
+func (store *Store) ChannelsRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var data any
+	var channelId = strings.Trim(strings.TrimPrefix(r.URL.Path, "/youtube/channels"), "/")
 	if channelId == "" {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The channelId is empty")
+		var sort = r.URL.Query().Get("sort")
+		if _, keyExists := channelSorts[sort]; sort != "" && keyExists == false {
+			responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The sort is invalid (should be either name/likeCount/dislikeCount/ratedCount/lastRatedAt)")
+			return
+		}
+		var channels, channelsErr = store.GetChannels(sort)
+		if channelsErr != nil {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, channelsErr, "Getting the channels from database")
+			return
+		}
+		data = channels
+	} else {
+		var channel, channelErr = store.GetChannel(channelId)
+		if channelErr != nil {
+			if channelErr == sql.ErrNoRows {
+				responses.SendSimpleErrorMessageResponse(w, http.StatusNotFound, "The channel is unknown")
+			} else {
+				responses.SendErrorResponse(w, http.StatusInternalServerError, channelErr, "Getting the channel from database")
+			}
+			return
+		}
+		data = channel
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(data); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the channels in JSON")
+	}
+}
+
+// Import the likes of a Google Takeout export, from the files on this computer.
+// The POST data is like: {"likedVideosFilePath": ".../Liked videos.csv", "watchHistoryFilePath": ".../watch-history.json"}
+//
+// The watch history is optional, but without it only the videos already in database can be imported.
+func (store *Store) ImportTakeoutRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
 		return
 	}
 
-	videoDescriptionValue, keyExists := postData["videoDescription"]
-	if keyExists == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoDescription given")
+	var postData struct {
+		LikedVideosFilePath  string `json:"likedVideosFilePath" validate:"required,nonempty"`
+		WatchHistoryFilePath string `json:"watchHistoryFilePath"`
+	}
+	if statusCode, decodeErr := validation.DecodeRequestBody(r, &postData); decodeErr != nil {
+		responses.SendRequestErrorResponse(w, statusCode, decodeErr, "Decoding the POST data")
 		return
 	}
-	videoDescription, typeOk := videoDescriptionValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoDescription is not a string")
+
+	var report, importErr = store.ImportTakeout(postData.LikedVideosFilePath, postData.WatchHistoryFilePath)
+	if importErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, importErr, "Importing the Google Takeout export")
 		return
 	}
 
-	videoDurationSecondsValue, keyExists := postData["videoDurationSeconds"]
-	if keyExists == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "No videoDurationSeconds given")
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(report); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the import report in JSON")
+	}
+}
+
+// The data of a rating sent in a batch: the same as for SetVideoRatingRequestHandler, with the date of the rating.
+type batchRatingPayload struct {
+	ratingPayload
+	// When I rated the video: the milliseconds since the epoch (like Date.now()) or a RFC 3339 date.
+	RatedAt any `json:"ratedAt" validate:"required"`
+}
+
+// Convert the data of the rating to a video to rate.
+func (payload *batchRatingPayload) toVideoToRate() (*videoToRate, error) {
+	var ratedAt, ratedAtErr = parseRatedAt(payload.RatedAt)
+	if ratedAtErr != nil {
+		return nil, ratedAtErr
+	}
+
+	var video = payload.ratingPayload.toVideoToRate()
+	video.RatedAt = ratedAt
+	return video, nil
+}
+
+// Convert the date of a rating sent by my Chrome extension to the format of the dates in database.
+//
+// A date in the future (the clock of the computer which sent it may be wrong) is replaced by now.
+func parseRatedAt(value any) (string, error) {
+	var ratedAt time.Time
+	switch typedValue := value.(type) {
+	case float64:
+		ratedAt = time.UnixMilli(int64(typedValue))
+	case string:
+		var parseErr error
+		ratedAt, parseErr = time.Parse(time.RFC3339Nano, typedValue)
+		if parseErr != nil {
+			return "", fmt.Errorf("The ratedAt is not a RFC 3339 date")
+		}
+	case nil:
+		return "", fmt.Errorf("No ratedAt given")
+	default:
+		return "", fmt.Errorf("The ratedAt is neither a number nor a string")
+	}
+
+	var now = time.Now()
+	if ratedAt.After(now) {
+		ratedAt = now
+	}
+	return dates.ToString(ratedAt.Local()), nil
+}
+
+// My Chrome extension couldn't send some ratings when the server was down, and sends them all at once.
+// The POST data is an array of ratings, like the one of SetVideoRatingRequestHandler with a "ratedAt".
+//
+// The valid ratings are saved in a single transaction, and a rating never overwrites a newer one.
+// The response contains the result of each rating.
+func (store *Store) SetVideoRatingsRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
+		return
+	}
+
+	var requestBody, requestBodyErr = io.ReadAll(r.Body)
+	if requestBodyErr != nil {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, requestBodyErr, "Reading POST data")
 		return
 	}
-	//.. The duration of the video should be sent as a string, because it's stored as a string by Youtube.
-	videoDurationSecondsString, typeOk := videoDurationSecondsValue.(string)
-	if typeOk == false {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoDurationSeconds is not a string")
+	if len(requestBody) == 0 {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The POST data is empty")
+		return
+	}
+
+	var items []json.RawMessage
+	if parseErr := json.Unmarshal(requestBody, &items); parseErr != nil {
+		responses.SendErrorResponse(w, http.StatusBadRequest, parseErr, "Parsing the POST data to JSON")
+		return
+	}
+
+	//.. The invalid ratings get their error, the valid ones are saved and get their result.
+	var results = make([]BatchRatingResult, len(items))
+	var videos []*videoToRate
+	var videoIndexes []int
+	for i, item := range items {
+		results[i] = BatchRatingResult{Index: i, Result: "error"}
+		var payload = batchRatingPayload{}
+		var decodeErr = validation.Decode(item, &payload)
+		results[i].VideoId = payload.VideoId
+		if decodeErr != nil {
+			results[i].Error = decodeErr.Error()
+			if fieldErrors, isFieldErrors := decodeErr.(validation.FieldErrors); isFieldErrors {
+				results[i].FieldErrors = fieldErrors
+			}
+			continue
+		}
+		var video, validateErr = payload.toVideoToRate()
+		if validateErr != nil {
+			results[i].Error = validateErr.Error()
+			continue
+		}
+		videos = append(videos, video)
+		videoIndexes = append(videoIndexes, i)
+	}
+
+	if len(videos) > 0 {
+		var videoResults, sqlErr = store.SetVideoRatings(videos)
+		if sqlErr != nil {
+			responses.SendErrorResponse(w, http.StatusInternalServerError, sqlErr, "Saving the ratings in database")
+			return
+		}
+		for j, videoResult := range videoResults {
+			videoResult.Index = videoIndexes[j]
+			results[videoIndexes[j]] = videoResult
+		}
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(results); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the results in JSON")
+	}
+}
+
+// The data of a video sent by my Chrome extension when I rate it.
+type ratingPayload struct {
+	VideoId          string `json:"videoId" validate:"required,nonempty"`
+	Rating           string `json:"rating" validate:"required,nonempty,enum=like|dislike|none"`
+	ChannelName      string `json:"channelName" validate:"required,nonempty"`
+	VideoTitle       string `json:"videoTitle" validate:"required,nonempty"`
+	ChannelId        string `json:"channelId" validate:"required,nonempty"`
+	VideoDescription string `json:"videoDescription" validate:"required"`
+	// The duration of the video should be sent as a string, because it's stored as a string by Youtube.
+	VideoDurationSeconds string `json:"videoDurationSeconds" validate:"required,numeric"`
+}
+
+// Convert the validated data of the rating to a video to rate.
+func (payload *ratingPayload) toVideoToRate() *videoToRate {
+	var videoDurationSeconds, _ = strconv.ParseInt(payload.VideoDurationSeconds, 10, 64)
+	return &videoToRate{VideoId: payload.VideoId, Rating: payload.Rating, ChannelName: payload.ChannelName, Title: payload.VideoTitle,
+		ChannelId: payload.ChannelId, Description: payload.VideoDescription, DurationSeconds: videoDurationSeconds}
+}
+
+// I just rated a Youtube video. My Chrome extension intercepted that and sent some video data to save them in database.
+func (store *Store) SetVideoRatingRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The request must be POST")
 		return
 	}
-	videoDurationSeconds, convErr := strconv.ParseInt(videoDurationSecondsString, 10, 64)
-	if convErr != nil {
-		responses.SendSimpleErrorMessageResponse(w, http.StatusBadRequest, "The videoDurationSeconds is not a integer")
+
+	var payload ratingPayload
+	if statusCode, decodeErr := validation.DecodeRequestBody(r, &payload); decodeErr != nil {
+		responses.SendRequestErrorResponse(w, statusCode, decodeErr, "Decoding the POST data")
 		return
 	}
 
-	var sqlError = SetVideoRating(videoId, rating, channelName, videoTitle, channelId, videoDescription, videoDurationSeconds)
-	if sqlError != nil {
+	var video = payload.toVideoToRate()
+	var sqlError = store.SetVideoRating(video.VideoId, video.Rating, video.ChannelName, video.Title, video.ChannelId, video.Description, video.DurationSeconds)
+	if fieldErrors, isFieldErrors := sqlError.(validation.FieldErrors); isFieldErrors {
+		responses.SendRequestErrorResponse(w, http.StatusBadRequest, fieldErrors, "Saving the rating in database")
+	} else if sqlError != nil {
 		responses.SendErrorResponse(w, http.StatusInternalServerError, sqlError, "Saving the rating in database")
 	}
 }
+
+// Get the mode of the caches and their statistics (size, hits, misses and evictions), to check that they are useful.
+func (store *Store) CacheDiagnosticsRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(store.GetCacheDiagnostics()); encodeErr == nil {
+		buffer.WriteTo(w)
+	} else {
+		responses.SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the cache diagnostics in JSON")
+	}
+}
@@ -0,0 +1,64 @@
+package youtube
+
+import (
+	"database/sql"
+)
+
+// A change of the rating of a video.
+type RatingUpdate struct {
+	OldRating string `json:"oldRating"`
+	NewRating string `json:"newRating"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// The current rating of a video and all the changes of its rating, the oldest first.
+type RatingHistory struct {
+	VideoId   string         `json:"videoId"`
+	Rating    string         `json:"rating"`
+	CreatedAt string         `json:"createdAt"`
+	Updates   []RatingUpdate `json:"updates"`
+}
+
+// Get the history of the ratings of the given video.
+//
+// Return sql.ErrNoRows if the video has never been rated or is deleted.
+func (store *Store) GetRatingHistory(videoId string) (*RatingHistory, error) {
+	var history = &RatingHistory{VideoId: videoId, Updates: []RatingUpdate{}}
+	var scanErr = store.connection.QueryRow("SELECT rating, created_at FROM videos WHERE video_id = ? AND deleted_at = '';", videoId).Scan(&history.Rating, &history.CreatedAt)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	var stmt, stmtErr = store.connection.Prepare("SELECT old_rating, new_rating, updated_at FROM rating_updates WHERE video_id = ? ORDER BY updated_at, rowid;")
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+	defer stmt.Close()
+
+	var rows, queryErr = stmt.Query(videoId)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var update = RatingUpdate{}
+		if scanErr := rows.Scan(&update.OldRating, &update.NewRating, &update.UpdatedAt); scanErr != nil {
+			return nil, scanErr
+		}
+		history.Updates = append(history.Updates, update)
+	}
+	return history, rows.Err()
+}
+
+// Keep in the history the change of the rating of a video.
+func insertRatingUpdate(transaction *sql.Tx, videoId string, oldRating string, newRating string, updatedAt string) error {
+	var stmt, stmtErr = transaction.Prepare("INSERT INTO rating_updates(video_id, old_rating, new_rating, updated_at) VALUES(?, ?, ?, ?);")
+	if stmtErr != nil {
+		return stmtErr
+	}
+	defer stmt.Close()
+
+	var _, execErr = stmt.Exec(videoId, oldRating, newRating, updatedAt)
+	return execErr
+}
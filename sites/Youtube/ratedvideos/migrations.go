@@ -0,0 +1,185 @@
+package youtube
+
+import (
+	"database/sql"
+	database "mylocalhost/utils/database"
+	dates "mylocalhost/utils/dates"
+)
+
+// The migrations of the schema of the database, in order. A new migration must be appended at the end.
+var migrations = []database.Migration{
+	{
+		Version:     1,
+		Description: "Create the channels and videos tables",
+		Script: `
+		CREATE TABLE IF NOT EXISTS "channels" ("id" INTEGER, "name" TEXT NOT NULL CHECK("name" != '') UNIQUE, "channel_id" TEXT NOT NULL CHECK("channel_id" != ''),
+		PRIMARY KEY("id"));
+
+		CREATE TABLE IF NOT EXISTS "videos" ("video_id" TEXT NOT NULL CHECK("video_id" != '') UNIQUE, "rating" TEXT NOT NULL CHECK("rating" != ''),
+		"channel_id" INTEGER NOT NULL, "title" TEXT NOT NULL CHECK("title" != ''),
+		"description" TEXT NOT NULL DEFAULT '', "comment" TEXT NOT NULL DEFAULT '',
+		"created_at" TEXT NOT NULL CHECK("created_at" != ''), "updated_at" TEXT NOT NULL DEFAULT '',
+		"downloaded_at" TEXT NOT NULL DEFAULT '', "deleted_at" TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY("channel_id") REFERENCES "channels"("id") ON DELETE RESTRICT ON UPDATE CASCADE);
+
+		CREATE INDEX IF NOT EXISTS "idx_channels_name" ON "channels" ("name");
+		CREATE INDEX IF NOT EXISTS "idx_videos_video_id" ON "videos" ("video_id");`,
+	},
+	{
+		Version:     2,
+		Description: "Add the duration_seconds column to the videos table",
+		Run: func(transaction *sql.Tx) error {
+			//.. The column may have been added by hand to some databases, to make the insert of the videos work.
+			var columnExists, columnErr = database.ColumnExists(transaction, "videos", "duration_seconds")
+			if columnErr != nil || columnExists {
+				return columnErr
+			}
+			var _, execErr = transaction.Exec(`ALTER TABLE "videos" ADD COLUMN "duration_seconds" INTEGER NOT NULL DEFAULT 0;`)
+			return execErr
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add the full-text search table of the videos",
+		//.. The search table keeps its own copy of the title and description, instead of using the videos table as external content,
+		//.. because the rowid of the videos table isn't an alias of a primary key and may change with a VACUUM.
+		Script: `
+		CREATE VIRTUAL TABLE "videos_fts" USING fts5("video_id" UNINDEXED, "title", "description");
+
+		CREATE TRIGGER "videos_fts_insert" AFTER INSERT ON "videos" BEGIN
+			INSERT INTO "videos_fts"("video_id", "title", "description") VALUES (new."video_id", new."title", new."description");
+		END;
+		CREATE TRIGGER "videos_fts_delete" AFTER DELETE ON "videos" BEGIN
+			DELETE FROM "videos_fts" WHERE "video_id" = old."video_id";
+		END;
+		CREATE TRIGGER "videos_fts_update" AFTER UPDATE OF "video_id", "title", "description" ON "videos" BEGIN
+			DELETE FROM "videos_fts" WHERE "video_id" = old."video_id";
+			INSERT INTO "videos_fts"("video_id", "title", "description") VALUES (new."video_id", new."title", new."description");
+		END;
+
+		INSERT INTO "videos_fts"("video_id", "title", "description") SELECT "video_id", "title", "description" FROM "videos";`,
+	},
+	{
+		Version:     4,
+		Description: "Add the rating_updates table",
+		Script: `
+		CREATE TABLE "rating_updates" ("video_id" TEXT NOT NULL CHECK("video_id" != ''), "old_rating" TEXT NOT NULL, "new_rating" TEXT NOT NULL,
+		"updated_at" TEXT NOT NULL CHECK("updated_at" != ''));
+
+		CREATE INDEX "idx_rating_updates_video_id" ON "rating_updates" ("video_id");`,
+	},
+	{
+		Version:     5,
+		Description: "Add the comment_updated_at column to the videos table",
+		Script:      `ALTER TABLE "videos" ADD COLUMN "comment_updated_at" TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		Version:     6,
+		Description: "Identify the channels by their channel_id and keep the history of their names",
+		Run:         mergeChannelsByChannelId,
+	},
+	{
+		Version:     7,
+		Description: "Add the change sequence of the videos, for the delta sync",
+		//.. The dates of the videos can't tell which videos changed since a sync, because an imported rating has the date it was made.
+		//.. So every insert or update of a video gives it the next number of a sequence.
+		//.. The last number is kept in the sync_state table, since MAX("change_seq") would go back when the video with the last change is purged.
+		Script: `
+		ALTER TABLE "videos" ADD COLUMN "change_seq" INTEGER NOT NULL DEFAULT 0;
+		UPDATE "videos" SET "change_seq" = "rowid";
+		CREATE INDEX "idx_videos_change_seq" ON "videos" ("change_seq");
+
+		CREATE TABLE "sync_state" ("key" TEXT NOT NULL PRIMARY KEY, "value" INTEGER NOT NULL);
+		INSERT INTO "sync_state"("key", "value") VALUES ('purged_change_seq', 0);
+		INSERT INTO "sync_state"("key", "value") VALUES ('last_change_seq', (SELECT COALESCE(MAX("change_seq"), 0) FROM "videos"));
+
+		CREATE TRIGGER "videos_change_seq_insert" AFTER INSERT ON "videos" BEGIN
+			UPDATE "sync_state" SET "value" = "value" + 1 WHERE "key" = 'last_change_seq';
+			UPDATE "videos" SET "change_seq" = (SELECT "value" FROM "sync_state" WHERE "key" = 'last_change_seq') WHERE "rowid" = new."rowid";
+		END;
+		CREATE TRIGGER "videos_change_seq_update" AFTER UPDATE ON "videos" WHEN new."change_seq" = old."change_seq" BEGIN
+			UPDATE "sync_state" SET "value" = "value" + 1 WHERE "key" = 'last_change_seq';
+			UPDATE "videos" SET "change_seq" = (SELECT "value" FROM "sync_state" WHERE "key" = 'last_change_seq') WHERE "rowid" = new."rowid";
+		END;`,
+	},
+}
+
+// Recreate the channels table with a unique channel_id instead of a unique name.
+//
+// When a channel was renamed, a second row was inserted for the same channel_id.
+// Those rows are merged into the oldest one, which takes the newest name, and the names are kept in the channel_renames table.
+func mergeChannelsByChannelId(transaction *sql.Tx) error {
+	var _, execErr = transaction.Exec(`
+	CREATE TABLE "channels_new" ("id" INTEGER, "channel_id" TEXT NOT NULL CHECK("channel_id" != '') UNIQUE, "name" TEXT NOT NULL CHECK("name" != ''),
+	PRIMARY KEY("id"));
+
+	CREATE TABLE "channel_renames" ("channel_id" INTEGER NOT NULL, "old_name" TEXT NOT NULL, "new_name" TEXT NOT NULL, "renamed_at" TEXT NOT NULL,
+	FOREIGN KEY("channel_id") REFERENCES "channels"("id") ON DELETE CASCADE ON UPDATE CASCADE);
+
+	CREATE INDEX "idx_channel_renames_channel_id" ON "channel_renames" ("channel_id");`)
+	if execErr != nil {
+		return execErr
+	}
+
+	type channelRow struct {
+		id        int64
+		channelId string
+		name      string
+		//.. The date of the first video rated with this row, to date the rename.
+		firstRatedAt string
+	}
+	var rows, queryErr = transaction.Query(`SELECT channels.id, channels.channel_id, channels.name, COALESCE(MIN(videos.created_at), '')
+		FROM channels LEFT JOIN videos ON videos.channel_id = channels.id
+		GROUP BY channels.id ORDER BY channels.channel_id, channels.id;`)
+	if queryErr != nil {
+		return queryErr
+	}
+	var channelRows []channelRow
+	for rows.Next() {
+		var row = channelRow{}
+		if scanErr := rows.Scan(&row.id, &row.channelId, &row.name, &row.firstRatedAt); scanErr != nil {
+			rows.Close()
+			return scanErr
+		}
+		channelRows = append(channelRows, row)
+	}
+	rows.Close()
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return rowsErr
+	}
+
+	var migratedAt = dates.NowToString()
+	for i := 0; i < len(channelRows); {
+		//.. The rows of the same channel follow each other, the oldest first.
+		var kept = channelRows[i]
+		var name = kept.name
+		var j = i + 1
+		for ; j < len(channelRows) && channelRows[j].channelId == kept.channelId; j++ {
+			var duplicate = channelRows[j]
+			if _, execErr := transaction.Exec(`UPDATE "videos" SET "channel_id" = ? WHERE "channel_id" = ?;`, kept.id, duplicate.id); execErr != nil {
+				return execErr
+			}
+			if duplicate.name != name {
+				var renamedAt = duplicate.firstRatedAt
+				if renamedAt == "" {
+					renamedAt = migratedAt
+				}
+				if _, execErr := transaction.Exec(`INSERT INTO "channel_renames"("channel_id", "old_name", "new_name", "renamed_at") VALUES(?, ?, ?, ?);`,
+					kept.id, name, duplicate.name, renamedAt); execErr != nil {
+					return execErr
+				}
+				name = duplicate.name
+			}
+		}
+		if _, execErr := transaction.Exec(`INSERT INTO "channels_new"("id", "channel_id", "name") VALUES(?, ?, ?);`, kept.id, kept.channelId, name); execErr != nil {
+			return execErr
+		}
+		i = j
+	}
+
+	_, execErr = transaction.Exec(`
+	DROP TABLE "channels";
+	ALTER TABLE "channels_new" RENAME TO "channels";
+	CREATE INDEX "idx_channels_name" ON "channels" ("name");`)
+	return execErr
+}
@@ -0,0 +1,214 @@
+package youtube
+
+import (
+	"fmt"
+	pagination "mylocalhost/utils/pagination"
+	"sort"
+	"strings"
+)
+
+// The filters, the sorting and the pagination of the rated videos to get.
+type RatedVideosQuery struct {
+	// like/dislike/none. Empty for every rating.
+	Rating string
+	// The name or the id of the channel.
+	Channel string
+	// The id of the channel, which is never taken for a name.
+	ChannelId string
+	// The dates are compared on their length, so "2024", "2024-05" or "2024-05-17" can be used.
+	// The upper bounds are inclusive.
+	CreatedFrom string
+	CreatedTo   string
+	UpdatedFrom string
+	UpdatedTo   string
+	// A part of the title, case insensitive.
+	Title string
+	// Only the videos having a comment.
+	WithComment bool
+	// Get also the videos I have deleted.
+	IncludeDeleted bool
+	// Only the videos inserted, updated or deleted since the sync token was given.
+	// The deleted videos are therefore included, and the videos are sorted in the order of their changes.
+	SyncToken string
+
+	// One of the keys of sortColumns. Empty to sort in the insertion order. It can't be given with a sync token.
+	Sort       string
+	Descending bool
+
+	// The maximum number of videos to get. 0 for all of them.
+	Limit int
+	// The cursor returned by the previous page.
+	Cursor string
+
+	// Get the title, channel, description, duration and dates of the videos, and not only their rating and comment.
+	WithDetails bool
+}
+
+// The columns the rated videos can be sorted by, by the name used in the requests.
+var sortColumns = map[string]string{
+	"createdAt":       "videos.created_at",
+	"updatedAt":       "videos.updated_at",
+	"title":           "videos.title",
+	"channelName":     "channels.name",
+	"durationSeconds": "videos.duration_seconds",
+}
+
+func (query *RatedVideosQuery) validate() error {
+	if query.Rating != "" && query.Rating != "like" && query.Rating != "dislike" && query.Rating != "none" {
+		return fmt.Errorf("The rating is invalid (should be either like/dislike/none)")
+	}
+	if query.Sort != "" {
+		if _, keyExists := sortColumns[query.Sort]; keyExists == false {
+			var sorts []string
+			for key := range sortColumns {
+				sorts = append(sorts, key)
+			}
+			sort.Strings(sorts)
+			return fmt.Errorf("The sort \"%s\" is invalid (should be one of %s)", query.Sort, strings.Join(sorts, "/"))
+		}
+	}
+	if query.Limit < 0 {
+		return fmt.Errorf("The limit can't be negative")
+	}
+	if query.SyncToken != "" {
+		if _, parseErr := parseSyncToken(query.SyncToken); parseErr != nil {
+			return parseErr
+		}
+		if query.Sort != "" || query.Descending {
+			return fmt.Errorf("The videos can't be sorted with a sync token, they are sorted in the order of their changes")
+		}
+	}
+	if query.Cursor != "" {
+		if _, cursorErr := pagination.DecodeCursorForSort(query.Cursor, query.sortKey(), query.Descending); cursorErr != nil {
+			return cursorErr
+		}
+	}
+	return nil
+}
+
+// The sort of the videos when a sync token is given. A page then ends with the newest change it contains,
+// so a video changed while the next pages are got is always after them.
+const changeSeqSort = "changeSeq"
+
+// Get the sort of the videos: the one asked, or the order of their changes with a sync token.
+func (query *RatedVideosQuery) sortKey() string {
+	if query.SyncToken != "" {
+		return changeSeqSort
+	}
+	return query.Sort
+}
+
+// Build the SQL query of the rated videos, and its arguments.
+func (query *RatedVideosQuery) toSQL() (string, []any, error) {
+	if validateErr := query.validate(); validateErr != nil {
+		return "", nil, validateErr
+	}
+
+	var sortColumn = "videos.rowid"
+	if query.SyncToken != "" {
+		sortColumn = "videos.change_seq"
+	} else if query.Sort != "" {
+		sortColumn = sortColumns[query.Sort]
+	}
+
+	var conditions []string
+	var args []any
+	if query.SyncToken != "" {
+		var changeSeq, _ = parseSyncToken(query.SyncToken)
+		conditions = append(conditions, "videos.change_seq > ?")
+		args = append(args, changeSeq)
+	} else if query.IncludeDeleted == false {
+		conditions = append(conditions, "videos.deleted_at = ''")
+	}
+	if query.Rating != "" {
+		conditions = append(conditions, "videos.rating = ?")
+		args = append(args, query.Rating)
+	}
+	if query.Channel != "" {
+		conditions = append(conditions, "(channels.name = ? OR channels.channel_id = ?)")
+		args = append(args, query.Channel, query.Channel)
+	}
+	if query.ChannelId != "" {
+		conditions = append(conditions, "channels.channel_id = ?")
+		args = append(args, query.ChannelId)
+	}
+	if query.CreatedFrom != "" {
+		conditions = append(conditions, "videos.created_at >= ?")
+		args = append(args, query.CreatedFrom)
+	}
+	if query.CreatedTo != "" {
+		conditions = append(conditions, "substr(videos.created_at, 1, length(?)) <= ?")
+		args = append(args, query.CreatedTo, query.CreatedTo)
+	}
+	if query.UpdatedFrom != "" {
+		conditions = append(conditions, "videos.updated_at >= ?")
+		args = append(args, query.UpdatedFrom)
+	}
+	if query.UpdatedTo != "" {
+		conditions = append(conditions, "videos.updated_at != '' AND substr(videos.updated_at, 1, length(?)) <= ?")
+		args = append(args, query.UpdatedTo, query.UpdatedTo)
+	}
+	if query.Title != "" {
+		conditions = append(conditions, "instr(lower(videos.title), lower(?)) > 0")
+		args = append(args, query.Title)
+	}
+	if query.WithComment {
+		conditions = append(conditions, "videos.comment != ''")
+	}
+
+	if query.Cursor != "" {
+		var cursor, cursorErr = pagination.DecodeCursor(query.Cursor)
+		if cursorErr != nil {
+			return "", nil, cursorErr
+		}
+		var condition, conditionArgs = cursor.Condition(sortColumn, "videos.rowid")
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	var sqlQuery = `SELECT videos.rowid, videos.video_id, videos.rating, videos.title, channels.name, channels.channel_id,
+		videos.description, videos.duration_seconds, videos.created_at, videos.updated_at, videos.comment, videos.comment_updated_at,
+		videos.downloaded_at, videos.deleted_at, videos.change_seq
+		FROM videos INNER JOIN channels ON channels.id = videos.channel_id`
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var order = "ASC"
+	if query.Descending {
+		order = "DESC"
+	}
+	if query.sortKey() == "" {
+		sqlQuery += " ORDER BY videos.rowid " + order
+	} else {
+		sqlQuery += fmt.Sprintf(" ORDER BY %s %s, videos.rowid %s", sortColumn, order, order)
+	}
+
+	if query.Limit > 0 {
+		//.. I get one more video to know if there is a next page.
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+	return sqlQuery + ";", args, nil
+}
+
+// Make the cursor of the page following the given video.
+func (query *RatedVideosQuery) nextCursor(lastVideo *RatedVideo) (string, error) {
+	var cursor = pagination.Cursor{Sort: query.sortKey(), Descending: query.Descending, Rowid: lastVideo.Rowid}
+	switch cursor.Sort {
+	case "createdAt":
+		cursor.Value = lastVideo.CreatedAt
+	case "updatedAt":
+		cursor.Value = lastVideo.UpdatedAt
+	case "title":
+		cursor.Value = lastVideo.Title
+	case "channelName":
+		cursor.Value = lastVideo.ChannelName
+	case "durationSeconds":
+		cursor.Value = lastVideo.DurationSeconds
+	case changeSeqSort:
+		cursor.Value = lastVideo.changeSeq
+	}
+
+	return cursor.Encode()
+}
@@ -0,0 +1,110 @@
+package youtube
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// A rated video matching a full-text search.
+type SearchResult struct {
+	VideoId     string `json:"videoId"`
+	Rating      string `json:"rating"`
+	Title       string `json:"title"`
+	ChannelName string `json:"channelName"`
+	ChannelId   string `json:"channelId"`
+	// The title escaped in HTML, with the matching terms surrounded by <mark></mark>.
+	TitleHighlight string `json:"titleHighlight"`
+	// An extract of the description around the matching terms, escaped in HTML and with the terms surrounded by <mark></mark>.
+	DescriptionSnippet string `json:"descriptionSnippet"`
+	// The bm25 score of the match. The lower the better.
+	Rank float64 `json:"rank"`
+}
+
+// Search the given terms in the title and description of the rated videos, the best matches first.
+//
+// The rating and channel (name or id) filters are optional. The deleted videos are ignored, unless includeDeleted is true.
+func (store *Store) SearchRatedVideos(terms string, rating string, channel string, includeDeleted bool, limit int) ([]SearchResult, error) {
+	var match = toMatchExpression(terms)
+	if match == "" {
+		return nil, fmt.Errorf("The search terms are empty")
+	}
+
+	var sqlQuery = `SELECT videos.video_id, videos.rating, videos.title, channels.name, channels.channel_id,
+		highlight(videos_fts, 1, char(2), char(3)), snippet(videos_fts, 2, char(2), char(3), '…', 16), videos_fts.rank
+		FROM videos_fts
+		INNER JOIN videos ON videos.video_id = videos_fts.video_id
+		INNER JOIN channels ON channels.id = videos.channel_id
+		WHERE videos_fts MATCH ?`
+	var args = []any{match}
+	if includeDeleted == false {
+		sqlQuery += " AND videos.deleted_at = ''"
+	}
+	if rating != "" {
+		sqlQuery += " AND videos.rating = ?"
+		args = append(args, rating)
+	}
+	if channel != "" {
+		sqlQuery += " AND (channels.name = ? OR channels.channel_id = ?)"
+		args = append(args, channel, channel)
+	}
+	sqlQuery += " ORDER BY videos_fts.rank LIMIT ?;"
+	args = append(args, limit)
+
+	var stmt, stmtErr = store.connection.Prepare(sqlQuery)
+	if stmtErr != nil {
+		return nil, stmtErr
+	}
+	defer stmt.Close()
+
+	var rows, queryErr = stmt.Query(args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	var results = []SearchResult{}
+	for rows.Next() {
+		var result = SearchResult{}
+		if scanErr := rows.Scan(&result.VideoId, &result.Rating, &result.Title, &result.ChannelName, &result.ChannelId,
+			&result.TitleHighlight, &result.DescriptionSnippet, &result.Rank); scanErr != nil {
+			return nil, scanErr
+		}
+		result.TitleHighlight = markMatches(result.TitleHighlight)
+		result.DescriptionSnippet = markMatches(result.DescriptionSnippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// The characters around the matching terms given by highlight() and snippet(), which can't be in a title or a description.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// Escape the text in HTML, then surround the matching terms by <mark></mark>, so the text can't inject some HTML.
+func markMatches(text string) string {
+	return strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>").Replace(html.EscapeString(text))
+}
+
+// Convert the terms typed by the user into a FTS5 query where every term must match.
+//
+// Each term is quoted so the characters having a meaning for FTS5 (like "-" or ":") don't make the query invalid.
+// A term ending with "*" is kept as a prefix search.
+func toMatchExpression(terms string) string {
+	var expressions []string
+	for _, term := range strings.Fields(terms) {
+		var prefix = strings.HasSuffix(term, "*")
+		term = strings.TrimRight(term, "*")
+		if term == "" {
+			continue
+		}
+		var expression = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		if prefix {
+			expression += "*"
+		}
+		expressions = append(expressions, expression)
+	}
+	return strings.Join(expressions, " ")
+}
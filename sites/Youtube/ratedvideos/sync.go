@@ -0,0 +1,74 @@
+package youtube
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// The error when a sync token is older than the last purge: the purged videos can't be sent as removed,
+// so all the videos must be got again.
+var ErrSyncTokenExpired = errors.New("The sync token is older than the last purge of the deleted videos, all the videos must be got again")
+
+// The state of the rated videos, to know if they have changed since a previous request.
+type SyncState struct {
+	// The number of the last change of a video, which never goes back even when the videos are purged.
+	ChangeSeq int64
+	Count     int64
+	// The number of the last change of the purged videos.
+	PurgedChangeSeq int64
+}
+
+func (store *Store) GetSyncState() (*SyncState, error) {
+	var state = &SyncState{}
+	var scanErr = store.connection.QueryRow(`SELECT (SELECT value FROM sync_state WHERE key = 'last_change_seq'), COUNT(*),
+		(SELECT value FROM sync_state WHERE key = 'purged_change_seq')
+		FROM videos;`).Scan(&state.ChangeSeq, &state.Count, &state.PurgedChangeSeq)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return state, nil
+}
+
+// The token to give to the next request, to get only the videos which will have changed.
+func (state *SyncState) Token() string {
+	return strconv.FormatInt(state.ChangeSeq, 10)
+}
+
+// The ETag of the rated videos. The count changes when some videos are purged.
+func (state *SyncState) ETag() string {
+	return fmt.Sprintf(`"%d-%d"`, state.ChangeSeq, state.Count)
+}
+
+// Check that the videos changed since the given token can still be got.
+func (state *SyncState) CheckToken(token string) error {
+	var changeSeq, parseErr = parseSyncToken(token)
+	if parseErr != nil {
+		return parseErr
+	}
+	if changeSeq < state.PurgedChangeSeq {
+		return ErrSyncTokenExpired
+	}
+	return nil
+}
+
+func parseSyncToken(token string) (int64, error) {
+	var changeSeq, parseErr = strconv.ParseInt(token, 10, 64)
+	if parseErr != nil || changeSeq < 0 {
+		return 0, fmt.Errorf("The sync token is invalid")
+	}
+	return changeSeq, nil
+}
+
+// Keep the number of the last change of the videos being purged, to know which sync tokens are expired.
+func setPurgedChangeSeq(transaction *sql.Tx, videoIds []string) error {
+	for _, videoId := range videoIds {
+		var _, execErr = transaction.Exec(`UPDATE sync_state SET value = MAX(value, (SELECT change_seq FROM videos WHERE video_id = ?))
+			WHERE key = 'purged_change_seq';`, videoId)
+		if execErr != nil {
+			return execErr
+		}
+	}
+	return nil
+}
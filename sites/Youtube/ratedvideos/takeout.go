@@ -0,0 +1,230 @@
+package youtube
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	dates "mylocalhost/utils/dates"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// What the import of a Google Takeout export has done.
+type TakeoutImportReport struct {
+	// The number of videos in the "Liked videos" playlist.
+	LikedCount int `json:"likedCount"`
+	Inserted   int `json:"inserted"`
+	// The videos I liked after having rated them differently in database.
+	Updated int `json:"updated"`
+	// The videos already liked in database.
+	Unchanged int `json:"unchanged"`
+	// The videos not in database, and whose title and channel are not in the watch history, so they can't be inserted.
+	Skipped         int      `json:"skipped"`
+	SkippedVideoIds []string `json:"skippedVideoIds"`
+	// The videos I rated differently in database after having liked them.
+	Conflicting         int      `json:"conflicting"`
+	ConflictingVideoIds []string `json:"conflictingVideoIds"`
+}
+
+// A video I liked, from the "Liked videos" playlist of Google Takeout.
+type takeoutLike struct {
+	VideoId string
+	// Formatted like the dates in database.
+	LikedAt string
+}
+
+// The title and channel of a video, from the watch history of Google Takeout.
+type takeoutVideo struct {
+	Title       string
+	ChannelName string
+	ChannelId   string
+}
+
+// Import the likes of the "Liked videos" playlist of a Google Takeout export (its CSV file),
+// with the titles and channels of the watch history (its JSON file).
+//
+// The videos are inserted or updated like a rating made at the date they were liked, all of them in the same transaction.
+// So a like never overwrites a rating I made after it.
+func (store *Store) ImportTakeout(likedVideosFilePath string, watchHistoryFilePath string) (*TakeoutImportReport, error) {
+	var likes, likesErr = readTakeoutLikedVideos(likedVideosFilePath)
+	if likesErr != nil {
+		return nil, fmt.Errorf("Reading the liked videos: %w", likesErr)
+	}
+
+	var likedVideoIds = make(map[string]bool)
+	for _, like := range likes {
+		likedVideoIds[like.VideoId] = true
+	}
+	var videos, historyErr = readTakeoutWatchHistory(watchHistoryFilePath, likedVideoIds)
+	if historyErr != nil {
+		return nil, fmt.Errorf("Reading the watch history: %w", historyErr)
+	}
+
+	var report = &TakeoutImportReport{LikedCount: len(likes), SkippedVideoIds: []string{}, ConflictingVideoIds: []string{}}
+	var transaction, transactionErr = store.begin()
+	if transactionErr != nil {
+		return nil, transactionErr
+	}
+	defer transaction.Rollback()
+
+	for _, like := range likes {
+		var videoToLike = &videoToRate{VideoId: like.VideoId, Rating: "like", RatedAt: like.LikedAt}
+		if video, keyExists := videos[like.VideoId]; keyExists {
+			videoToLike.Title = video.Title
+			videoToLike.ChannelName = video.ChannelName
+			videoToLike.ChannelId = video.ChannelId
+		} else {
+			//.. Without its title and channel, a video can only be compared to the one in database.
+			var _, getErr = store.getVideoFromVideoId(transaction, like.VideoId)
+			if getErr == sql.ErrNoRows {
+				report.Skipped++
+				report.SkippedVideoIds = append(report.SkippedVideoIds, like.VideoId)
+				continue
+			} else if getErr != nil {
+				return nil, getErr
+			}
+		}
+
+		var outcome, rateErr = store.rateVideo(transaction, videoToLike)
+		if rateErr != nil {
+			return nil, fmt.Errorf("Importing the video %s: %w", like.VideoId, rateErr)
+		}
+		switch outcome {
+		case rateInserted:
+			report.Inserted++
+		case rateUpdated:
+			report.Updated++
+		case rateUnchanged:
+			report.Unchanged++
+		case rateOutdated:
+			report.Conflicting++
+			report.ConflictingVideoIds = append(report.ConflictingVideoIds, like.VideoId)
+		}
+	}
+
+	if commitErr := transaction.commit(); commitErr != nil {
+		return nil, commitErr
+	}
+	return report, nil
+}
+
+// Read the CSV file of the "Liked videos" playlist.
+//
+// The recent exports only contain the header "Video ID,Playlist Video Creation Timestamp" and the videos.
+// The older ones start with some rows about the playlist, followed by an empty line, the header "Video Id,Time Added" and the videos.
+func readTakeoutLikedVideos(filePath string) ([]takeoutLike, error) {
+	var file, openErr = os.Open(filePath)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	var reader = csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	var records, readErr = reader.ReadAll()
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var likes []takeoutLike
+	var headerFound = false
+	for _, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		var firstColumn = strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff"))
+		if headerFound == false {
+			headerFound = strings.EqualFold(firstColumn, "Video ID")
+			continue
+		}
+		if firstColumn == "" {
+			continue
+		}
+		var likedAt, parseErr = parseTakeoutTime(record[1])
+		if parseErr != nil {
+			return nil, fmt.Errorf("The date of the video %s is invalid: %w", firstColumn, parseErr)
+		}
+		likes = append(likes, takeoutLike{VideoId: firstColumn, LikedAt: likedAt})
+	}
+	if headerFound == false {
+		return nil, fmt.Errorf("The header \"Video ID\" is not found")
+	}
+	return likes, nil
+}
+
+// Read the title and channel of the given videos in the JSON file of the watch history.
+//
+// The file can be huge, so it's decoded entry by entry.
+// The entries are sorted from the newest to the oldest, so the first entry of a video has its current title and channel name.
+func readTakeoutWatchHistory(filePath string, videoIds map[string]bool) (map[string]takeoutVideo, error) {
+	var videos = make(map[string]takeoutVideo)
+	if filePath == "" {
+		return videos, nil
+	}
+
+	var file, openErr = os.Open(filePath)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	var decoder = json.NewDecoder(file)
+	if _, tokenErr := decoder.Token(); tokenErr != nil {
+		return nil, tokenErr
+	}
+	for decoder.More() {
+		var entry struct {
+			Title     string `json:"title"`
+			TitleUrl  string `json:"titleUrl"`
+			Subtitles []struct {
+				Name string `json:"name"`
+				Url  string `json:"url"`
+			} `json:"subtitles"`
+		}
+		if decodeErr := decoder.Decode(&entry); decodeErr != nil {
+			return nil, decodeErr
+		}
+
+		var videoId = videoIdFromUrl(entry.TitleUrl)
+		if videoId == "" || videoIds[videoId] == false || len(entry.Subtitles) == 0 {
+			continue
+		}
+		if _, keyExists := videos[videoId]; keyExists {
+			continue
+		}
+		//.. The title of a video removed from Youtube is its url.
+		var title = strings.TrimPrefix(entry.Title, "Watched ")
+		var channelId = strings.TrimPrefix(entry.Subtitles[0].Url, "https://www.youtube.com/channel/")
+		if title == "" || title == entry.TitleUrl || channelId == "" || channelId == entry.Subtitles[0].Url {
+			continue
+		}
+		videos[videoId] = takeoutVideo{Title: title, ChannelName: entry.Subtitles[0].Name, ChannelId: channelId}
+	}
+	return videos, nil
+}
+
+func videoIdFromUrl(videoUrl string) string {
+	var parsedUrl, parseErr = url.Parse(videoUrl)
+	if parseErr != nil {
+		return ""
+	}
+	return parsedUrl.Query().Get("v")
+}
+
+// Convert a date of Google Takeout (UTC) to the format of the dates in database (local time).
+func parseTakeoutTime(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	var layouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05 MST"}
+	var lastErr error
+	for _, layout := range layouts {
+		var t, parseErr = time.Parse(layout, value)
+		if parseErr == nil {
+			return dates.ToString(t.Local()), nil
+		}
+		lastErr = parseErr
+	}
+	return "", lastErr
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A cache keeping the most recently used values, safe for concurrent use.
+// When it's full, the least recently used value is evicted.
+type LRU[K comparable, V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	// The entries, from the most recently used to the least recently used.
+	entries   *list.List
+	elements  map[K]*list.Element
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// The statistics of a cache, since it was created.
+type Stats struct {
+	Size      int   `json:"size"`
+	Capacity  int   `json:"capacity"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// Create a cache keeping at most `capacity` values (at least 1).
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU[K, V]{capacity: capacity, entries: list.New(), elements: make(map[K]*list.Element)}
+}
+
+// Get the value of the key, and whether it's in the cache.
+func (cache *LRU[K, V]) Get(key K) (V, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	var element, keyExists = cache.elements[key]
+	if keyExists == false {
+		cache.misses++
+		var zero V
+		return zero, false
+	}
+	cache.hits++
+	cache.entries.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// Add or replace the value of the key.
+func (cache *LRU[K, V]) Set(key K, value V) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, keyExists := cache.elements[key]; keyExists {
+		element.Value.(*lruEntry[K, V]).value = value
+		cache.entries.MoveToFront(element)
+		return
+	}
+	cache.elements[key] = cache.entries.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if cache.entries.Len() > cache.capacity {
+		var oldest = cache.entries.Back()
+		cache.entries.Remove(oldest)
+		delete(cache.elements, oldest.Value.(*lruEntry[K, V]).key)
+		cache.evictions++
+	}
+}
+
+func (cache *LRU[K, V]) Remove(key K) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, keyExists := cache.elements[key]; keyExists {
+		cache.entries.Remove(element)
+		delete(cache.elements, key)
+	}
+}
+
+// Remove all the values. The statistics are kept.
+func (cache *LRU[K, V]) Clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.entries.Init()
+	cache.elements = make(map[K]*list.Element)
+}
+
+func (cache *LRU[K, V]) Stats() Stats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return Stats{Size: cache.entries.Len(), Capacity: cache.capacity, Hits: cache.hits, Misses: cache.misses, Evictions: cache.evictions}
+}
@@ -2,15 +2,59 @@ package utils
 
 import (
 	"database/sql"
+	"fmt"
+	"mylocalhost/config"
 	"mylocalhost/utils"
+	"net/url"
 )
 
-func OpenSQLiteConnection(dbFilePath string) (*sql.DB, bool, error) {
+// The options set on each connection to a SQLite database. The foreign keys are always enforced.
+type ConnectionOptions struct {
+	// DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF.
+	JournalMode string
+	// How long a connection waits for a database locked by another one, in milliseconds.
+	BusyTimeoutMs int
+	// 0 means no limit.
+	MaxOpenConnections int
+	MaxIdleConnections int
+}
+
+// Read the connection options from the config "database.journalMode", "database.busyTimeoutMs",
+// "database.maxOpenConnections" and "database.maxIdleConnections".
+func ReadConnectionOptions() ConnectionOptions {
+	return ConnectionOptions{
+		JournalMode:        config.Get("database.journalMode"),
+		BusyTimeoutMs:      config.GetInt("database.busyTimeoutMs", 5000),
+		MaxOpenConnections: config.GetInt("database.maxOpenConnections", 0),
+		MaxIdleConnections: config.GetInt("database.maxIdleConnections", 2),
+	}
+}
+
+func OpenSQLiteConnection(dbFilePath string, options ConnectionOptions) (*sql.DB, bool, error) {
 	var dbFileExists, fileExistsErr = utils.FileExists(dbFilePath)
 	if fileExistsErr != nil {
 		return nil, false, fileExistsErr
 	}
 
-	var db, openErr = sql.Open("sqlite3", dbFilePath)
-	return db, dbFileExists, openErr
+	//.. The options are given in the DSN, so the driver sets them on every connection of the pool.
+	var params = url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", fmt.Sprint(options.BusyTimeoutMs))
+	if options.JournalMode != "" {
+		params.Set("_journal_mode", options.JournalMode)
+	}
+
+	var db, openErr = sql.Open("sqlite3", dbFilePath+"?"+params.Encode())
+	if openErr != nil {
+		return nil, dbFileExists, openErr
+	}
+	db.SetMaxOpenConns(options.MaxOpenConnections)
+	db.SetMaxIdleConns(options.MaxIdleConnections)
+
+	//.. sql.Open doesn't connect, so I check now that the database can be opened with these options.
+	if pingErr := db.Ping(); pingErr != nil {
+		db.Close()
+		return nil, dbFileExists, pingErr
+	}
+	return db, dbFileExists, nil
 }
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"mylocalhost/logger"
+)
+
+// A change of the schema of a database.
+type Migration struct {
+	// The schema version of the database once the migration is applied.
+	// The versions of a migration list start at 1 and follow each other.
+	Version     int
+	Description string
+	// The SQL executed by the migration. Can be empty if Run is set.
+	Script string
+	// Some code executed by the migration after Script, for the changes that can't be made with SQL only.
+	Run func(transaction *sql.Tx) error
+}
+
+// Bring the schema of the database up to date, by applying in order the migrations
+// whose version is greater than the schema version of the database.
+//
+// The schema version is stored in "PRAGMA user_version" (0 for a new database).
+// Each migration is applied in its own transaction, which also sets the new schema version,
+// so a failed migration leaves the database at the version of the previous one.
+func Migrate(connection *sql.DB, databaseName string, migrations []Migration) error {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return fmt.Errorf("The migration \"%s\" of the database %s has the version %d, %d was expected", migration.Description, databaseName, migration.Version, i+1)
+		}
+	}
+
+	//.. The foreign keys must be disabled while the migrations recreate some tables,
+	//.. and this pragma can't be changed in a transaction, therefore all the migrations are made with the same connection.
+	var ctx = context.Background()
+	var conn, connErr = connection.Conn(ctx)
+	if connErr != nil {
+		return connErr
+	}
+	defer conn.Close()
+
+	var currentVersion int
+	if scanErr := conn.QueryRowContext(ctx, "PRAGMA user_version;").Scan(&currentVersion); scanErr != nil {
+		return scanErr
+	}
+	var latestVersion = len(migrations)
+	if currentVersion > latestVersion {
+		return fmt.Errorf("The schema version of the database %s is %d, but this program only knows the version %d", databaseName, currentVersion, latestVersion)
+	}
+	if currentVersion == latestVersion {
+		return nil
+	}
+
+	var foreignKeys int
+	if scanErr := conn.QueryRowContext(ctx, "PRAGMA foreign_keys;").Scan(&foreignKeys); scanErr != nil {
+		return scanErr
+	}
+	if foreignKeys == 1 {
+		if _, execErr := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF;"); execErr != nil {
+			return execErr
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON;")
+	}
+
+	for _, migration := range migrations[currentVersion:] {
+		if migrateErr := applyMigration(ctx, conn, migration); migrateErr != nil {
+			logger.WriteError("[database.Migrate] The migration %d (%s) of the database %s has failed:\n%v", migration.Version, migration.Description, databaseName, migrateErr)
+			return fmt.Errorf("The migration %d (%s) of the database %s has failed: %w", migration.Version, migration.Description, databaseName, migrateErr)
+		}
+		logger.WriteLog("[database.Migrate] Database %s migrated to the version %d (%s)", databaseName, migration.Version, migration.Description)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	var transaction, transactionErr = conn.BeginTx(ctx, nil)
+	if transactionErr != nil {
+		return transactionErr
+	}
+	defer transaction.Rollback()
+
+	if migration.Script != "" {
+		if _, execErr := transaction.Exec(migration.Script); execErr != nil {
+			return execErr
+		}
+	}
+	if migration.Run != nil {
+		if runErr := migration.Run(transaction); runErr != nil {
+			return runErr
+		}
+	}
+
+	//.. The foreign keys are disabled during the migration, so I check that it hasn't broken any of them.
+	var rows, checkErr = transaction.Query("PRAGMA foreign_key_check;")
+	if checkErr != nil {
+		return checkErr
+	}
+	var hasViolation = rows.Next()
+	rows.Close()
+	if hasViolation {
+		return fmt.Errorf("The migration has broken some foreign keys")
+	}
+
+	if _, execErr := transaction.Exec(fmt.Sprintf("PRAGMA user_version = %d;", migration.Version)); execErr != nil {
+		return execErr
+	}
+	return transaction.Commit()
+}
+
+// Indicate if the given column exists in the given table.
+func ColumnExists(transaction *sql.Tx, table string, column string) (bool, error) {
+	var count int
+	var scanErr = transaction.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;", table, column).Scan(&count)
+	return count > 0, scanErr
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// The position of the last row of a page, from which the next page starts.
+type Cursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d"`
+	// The value of the sort column of the row. nil when the rows are sorted by rowid.
+	Value any   `json:"v"`
+	Rowid int64 `json:"r"`
+}
+
+// Encode the cursor in a string which can be sent in a URL.
+func (cursor *Cursor) Encode() (string, error) {
+	var data, marshalErr = json.Marshal(cursor)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func DecodeCursor(encodedCursor string) (*Cursor, error) {
+	var data, decodeErr = base64.RawURLEncoding.DecodeString(encodedCursor)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("The cursor is invalid")
+	}
+	var cursor = &Cursor{}
+	if unmarshalErr := json.Unmarshal(data, cursor); unmarshalErr != nil {
+		return nil, fmt.Errorf("The cursor is invalid")
+	}
+	return cursor, nil
+}
+
+// Decode the cursor and check that it was made for the given sort.
+func DecodeCursorForSort(encodedCursor string, sort string, descending bool) (*Cursor, error) {
+	var cursor, cursorErr = DecodeCursor(encodedCursor)
+	if cursorErr != nil {
+		return nil, cursorErr
+	}
+	if cursor.Sort != sort || cursor.Descending != descending {
+		return nil, fmt.Errorf("The cursor was made for another sort")
+	}
+	return cursor, nil
+}
+
+// Get the SQL condition of the rows after the cursor, and its arguments.
+//
+// sortColumn is the column of the sort of the cursor, the rows being sorted by it then by rowidColumn.
+// It's ignored when the cursor has no sort, the rows being only sorted by rowidColumn.
+func (cursor *Cursor) Condition(sortColumn string, rowidColumn string) (string, []any) {
+	var operator = ">"
+	if cursor.Descending {
+		operator = "<"
+	}
+	if cursor.Sort == "" {
+		return rowidColumn + " " + operator + " ?", []any{cursor.Rowid}
+	}
+	return fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", sortColumn, operator, sortColumn, rowidColumn, operator),
+		[]any{cursor.Value, cursor.Value, cursor.Rowid}
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"mylocalhost/logger"
+	validation "mylocalhost/utils/validation"
 	"net/http"
 )
 
@@ -42,3 +44,29 @@ func SendErrorMessageResponse(w http.ResponseWriter, statusCode int, errMessage
 		w.Write(errorBytes)
 	}
 }
+
+// Send the error of the decoding of a request (see validation.DecodeRequestBody).
+// When some fields are invalid, all their problems are sent in "fieldErrors", like: [{"field": "rating", "message": "..."}]
+func SendRequestErrorResponse(w http.ResponseWriter, statusCode int, err error, operation string) {
+	var fieldErrors validation.FieldErrors
+	if errors.As(err, &fieldErrors) == false {
+		SendErrorResponse(w, statusCode, err, operation)
+		return
+	}
+
+	var data = map[string]any{
+		"error":       fieldErrors.Error(),
+		"fieldErrors": fieldErrors,
+	}
+	if operation != "" {
+		data["operation"] = operation
+	}
+
+	var buffer bytes.Buffer
+	if encodeErr := json.NewEncoder(&buffer).Encode(data); encodeErr == nil {
+		w.WriteHeader(statusCode)
+		buffer.WriteTo(w)
+	} else {
+		SendErrorResponse(w, http.StatusInternalServerError, encodeErr, "Encoding the field errors in JSON")
+	}
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The problem with a field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// All the problems with the fields of a request.
+type FieldErrors []FieldError
+
+func (fieldErrors FieldErrors) Error() string {
+	var messages []string
+	for _, fieldError := range fieldErrors {
+		messages = append(messages, fieldError.Message)
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Read the JSON body of the request, decode it into `target` (a pointer to a struct) and validate its fields (see Decode).
+//
+// Return the HTTP status code of the error: 500 if the body can't be read, 400 otherwise.
+func DecodeRequestBody(r *http.Request, target any) (int, error) {
+	var requestBody, requestBodyErr = io.ReadAll(r.Body)
+	if requestBodyErr != nil {
+		return http.StatusInternalServerError, requestBodyErr
+	}
+	if len(requestBody) == 0 {
+		return http.StatusBadRequest, fmt.Errorf("The POST data is empty")
+	}
+	if decodeErr := Decode(requestBody, target); decodeErr != nil {
+		return http.StatusBadRequest, decodeErr
+	}
+	return http.StatusOK, nil
+}
+
+// Decode the JSON object into `target` (a pointer to a struct) and validate its fields.
+//
+// The rules of a field are declared in its tag "validate", separated by commas:
+//   - required: the field must be given (and not null).
+//   - nonempty: the string or the array can't be empty.
+//   - enum=a|b|c: the string must be one of the values.
+//   - numeric: the string must be a integer.
+//
+// The fields are matched like encoding/json does. All the problems are returned together in a FieldErrors,
+// unless the data is not a JSON object.
+func Decode(data []byte, target any) error {
+	var values map[string]json.RawMessage
+	if unmarshalErr := json.Unmarshal(data, &values); unmarshalErr != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(unmarshalErr, &typeErr) {
+			return fmt.Errorf("The data is not a JSON object")
+		}
+		return unmarshalErr
+	}
+
+	var targetValue = reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Pointer || targetValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("The target of the decoding must be a pointer to a struct")
+	}
+
+	var fieldErrors = decodeStruct(values, targetValue.Elem(), FieldErrors{})
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+}
+
+func decodeStruct(values map[string]json.RawMessage, structValue reflect.Value, fieldErrors FieldErrors) FieldErrors {
+	var structType = structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		var name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			//.. Like encoding/json, the fields of an embedded struct (even unexported) are the fields of the struct embedding it.
+			fieldErrors = decodeStruct(values, structValue.Field(i), fieldErrors)
+			continue
+		}
+		if field.IsExported() == false {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		var fieldError = decodeField(values, name, structValue.Field(i), field.Tag.Get("validate"))
+		if fieldError != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Message: fieldError})
+		}
+	}
+	return fieldErrors
+}
+
+// Decode the value of the field and check its rules. Return the problem, or an empty string.
+func decodeField(values map[string]json.RawMessage, name string, fieldValue reflect.Value, rules string) string {
+	var rawValue, keyExists = values[name]
+	if keyExists == false {
+		for key, value := range values {
+			if strings.EqualFold(key, name) {
+				rawValue = value
+				keyExists = true
+				break
+			}
+		}
+	}
+	var given = keyExists && bytes.Equal(bytes.TrimSpace(rawValue), []byte("null")) == false
+
+	if given {
+		if unmarshalErr := json.Unmarshal(rawValue, fieldValue.Addr().Interface()); unmarshalErr != nil {
+			return fmt.Sprintf("The %s is not %s", name, typeDescription(fieldValue.Type()))
+		}
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		var ruleName, argument, _ = strings.Cut(strings.TrimSpace(rule), "=")
+		switch ruleName {
+		case "":
+		case "required":
+			if given == false {
+				return fmt.Sprintf("No %s given", name)
+			}
+		case "nonempty":
+			if (fieldValue.Kind() == reflect.String || fieldValue.Kind() == reflect.Slice) && fieldValue.Len() == 0 {
+				return fmt.Sprintf("The %s is empty", name)
+			}
+		case "enum":
+			var allowedValues = strings.Split(argument, "|")
+			var isAllowed = false
+			for _, allowedValue := range allowedValues {
+				if fieldValue.String() == allowedValue {
+					isAllowed = true
+					break
+				}
+			}
+			if given && isAllowed == false {
+				return fmt.Sprintf("The %s is invalid (should be either %s)", name, strings.Join(allowedValues, "/"))
+			}
+		case "numeric":
+			if _, convErr := strconv.ParseInt(fieldValue.String(), 10, 64); given && convErr != nil {
+				return fmt.Sprintf("The %s is not a integer", name)
+			}
+		default:
+			return fmt.Sprintf("The rule \"%s\" of the %s is unknown", ruleName, name)
+		}
+	}
+	return ""
+}
+
+func typeDescription(fieldType reflect.Type) string {
+	switch fieldType.Kind() {
+	case reflect.Pointer:
+		return typeDescription(fieldType.Elem())
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "valid"
+	}
+}